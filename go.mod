@@ -1,10 +1,11 @@
 module github.com/mshrtsr/mail-notice-linebot
 
-go 1.12
+go 1.13
 
 require (
 	github.com/emersion/go-imap v1.0.0
 	github.com/globalsign/mgo v0.0.0-20181015135952-eeefdecb41b8
 	github.com/joho/godotenv v1.3.0
 	github.com/line/line-bot-sdk-go v6.3.0+incompatible
+	golang.org/x/text v0.3.2
 )
@@ -1,6 +1,9 @@
 package helper
 
-import "os"
+import (
+	"os"
+	"strings"
+)
 
 // ConfigVars ..
 func ConfigVars() ConfigVariables {
@@ -14,11 +17,20 @@ func ConfigVars() ConfigVariables {
 		MongodbURI: os.Getenv("MONGODB_URI"),
 
 		HerokuAppName: os.Getenv("HEROKU_APP_NAME"),
+		AppBaseURL:    os.Getenv("APP_BASE_URL"),
+
+		TemplateDir:     os.Getenv("TEMPLATE_DIR"),
+		DefaultLanguage: os.Getenv("DEFAULT_LANGUAGE"),
+		TimeZone:        os.Getenv("TIME_ZONE"),
 
 		LineAPI: LineAPIConfigVariables{
 			ChannelID:     os.Getenv("LINE_CHANNEL_ID"),
 			ChannelSecret: os.Getenv("LINE_CHANNEL_SECRET"),
 			AccessToken:   os.Getenv("LINE_ACCESS_TOKEN"),
+			RichMenuFile:  os.Getenv("LINE_RICH_MENU_FILE"),
+
+			LIFFID:         os.Getenv("LINE_LIFF_ID"),
+			LoginChannelID: os.Getenv("LINE_LOGIN_CHANNEL_ID"),
 		},
 
 		SMTP: SMTPConfigVariables{
@@ -27,6 +39,26 @@ func ConfigVars() ConfigVariables {
 			ServerName:     os.Getenv("SMTP_SERVER_NAME"),
 			AuthUser:       os.Getenv("SMTP_AUTH_USER"),
 			AuthPassword:   os.Getenv("SMTP_AUTH_PASSWORD"),
+			Security:       os.Getenv("SMTP_SECURITY"),
+			AuthMechanism:  os.Getenv("SMTP_AUTH_MECHANISM"),
+			TimeoutSeconds: os.Getenv("SMTP_TIMEOUT"),
+
+			AllowRegisteredFrom: os.Getenv("SMTP_ALLOW_REGISTERED_FROM"),
+			Charset:             os.Getenv("SMTP_CHARSET"),
+		},
+
+		MailTransport: MailTransportConfigVariables{
+			Transport: os.Getenv("MAIL_TRANSPORT"),
+			HTTPURL:   os.Getenv("MAIL_HTTP_URL"),
+			HTTPToken: os.Getenv("MAIL_HTTP_TOKEN"),
+			SpoolDir:  os.Getenv("MAIL_SPOOL_DIR"),
+			LogBody:   os.Getenv("MAIL_LOG_BODY"),
+		},
+
+		DKIM: DKIMConfigVariables{
+			Domain:     os.Getenv("DKIM_DOMAIN"),
+			Selector:   os.Getenv("DKIM_SELECTOR"),
+			PrivateKey: os.Getenv("DKIM_PRIVATE_KEY"),
 		},
 
 		IMAP: IMAPConfigVariables{
@@ -36,9 +68,50 @@ func ConfigVars() ConfigVariables {
 			AuthPassword: os.Getenv("IMAP_AUTH_PASSWORD"),
 			MboxName:     os.Getenv("IMAP_MBOX_NAME"),
 		},
+
+		POP3: POP3ConfigVariables{
+			ServerName:   os.Getenv("POP3_SERVER_NAME"),
+			AuthUser:     os.Getenv("POP3_AUTH_USER"),
+			AuthPassword: os.Getenv("POP3_AUTH_PASSWORD"),
+			KeepMail:     os.Getenv("POP3_KEEP_MAIL"),
+		},
+
+		AddressMatch: AddressMatchConfigVariables{
+			CaseSensitiveLocalPart: os.Getenv("ADDRESS_CASE_SENSITIVE_LOCAL_PART"),
+			KeepSubAddress:         os.Getenv("ADDRESS_KEEP_SUBADDRESS"),
+			KeepGmailDots:          os.Getenv("ADDRESS_KEEP_GMAIL_DOTS"),
+		},
+
+		Attachment: AttachmentConfigVariables{
+			MaxBytes:        os.Getenv("ATTACHMENT_MAX_BYTES"),
+			ArchiveMaxBytes: os.Getenv("ARCHIVE_MAX_BYTES"),
+		},
+
+		Receiver: ReceiverConfigVariables{
+			ListenAddress:   os.Getenv("RECEIVER_LISTEN_ADDRESS"),
+			Domain:          os.Getenv("RECEIVER_DOMAIN"),
+			Protocol:        os.Getenv("RECEIVER_PROTOCOL"),
+			MaxMessageBytes: os.Getenv("RECEIVER_MAX_MESSAGE_BYTES"),
+		},
+
+		InboundParse: InboundParseConfigVariables{
+			Path:       os.Getenv("INBOUND_PARSE_PATH"),
+			SigningKey: os.Getenv("INBOUND_PARSE_SIGNING_KEY"),
+		},
 	}
 }
 
+// BaseURL returns the public URL of this app without a trailing slash
+func (c ConfigVariables) BaseURL() string {
+	if len(c.AppBaseURL) > 0 {
+		return strings.TrimSuffix(c.AppBaseURL, "/")
+	}
+	if len(c.HerokuAppName) > 0 {
+		return "https://" + c.HerokuAppName + ".herokuapp.com"
+	}
+	return ""
+}
+
 // ConfigVariables ..
 type ConfigVariables struct {
 	EnvLoaded    string
@@ -50,10 +123,25 @@ type ConfigVariables struct {
 	MongodbURI string
 
 	HerokuAppName string
+	AppBaseURL    string
+
+	TemplateDir     string
+	DefaultLanguage string
+	TimeZone        string
 
 	LineAPI LineAPIConfigVariables
 	SMTP    SMTPConfigVariables
 	IMAP    IMAPConfigVariables
+	POP3    POP3ConfigVariables
+
+	MailTransport MailTransportConfigVariables
+	DKIM          DKIMConfigVariables
+
+	AddressMatch AddressMatchConfigVariables
+	Attachment   AttachmentConfigVariables
+
+	Receiver     ReceiverConfigVariables
+	InboundParse InboundParseConfigVariables
 }
 
 // LineAPIConfigVariables ..
@@ -61,6 +149,10 @@ type LineAPIConfigVariables struct {
 	ChannelID     string
 	ChannelSecret string
 	AccessToken   string
+	RichMenuFile  string
+
+	LIFFID         string
+	LoginChannelID string
 }
 
 // SMTPConfigVariables ..
@@ -70,6 +162,28 @@ type SMTPConfigVariables struct {
 	ServerName     string
 	AuthUser       string
 	AuthPassword   string
+	Security       string
+	AuthMechanism  string
+	TimeoutSeconds string
+
+	AllowRegisteredFrom string
+	Charset             string
+}
+
+// MailTransportConfigVariables ..
+type MailTransportConfigVariables struct {
+	Transport string
+	HTTPURL   string
+	HTTPToken string
+	SpoolDir  string
+	LogBody   string
+}
+
+// DKIMConfigVariables ..
+type DKIMConfigVariables struct {
+	Domain     string
+	Selector   string
+	PrivateKey string
 }
 
 // IMAPConfigVariables ..
@@ -80,3 +194,38 @@ type IMAPConfigVariables struct {
 	AuthPassword string
 	MboxName     string
 }
+
+// POP3ConfigVariables ..
+type POP3ConfigVariables struct {
+	ServerName   string
+	AuthUser     string
+	AuthPassword string
+	KeepMail     string
+}
+
+// AddressMatchConfigVariables ..
+type AddressMatchConfigVariables struct {
+	CaseSensitiveLocalPart string
+	KeepSubAddress         string
+	KeepGmailDots          string
+}
+
+// AttachmentConfigVariables ..
+type AttachmentConfigVariables struct {
+	MaxBytes        string
+	ArchiveMaxBytes string
+}
+
+// ReceiverConfigVariables ..
+type ReceiverConfigVariables struct {
+	ListenAddress   string
+	Domain          string
+	Protocol        string
+	MaxMessageBytes string
+}
+
+// InboundParseConfigVariables ..
+type InboundParseConfigVariables struct {
+	Path       string
+	SigningKey string
+}
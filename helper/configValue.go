@@ -0,0 +1,19 @@
+package helper
+
+import (
+	"log"
+	"strconv"
+)
+
+// Int64OrDefault parses an integer config variable, returning defaultValue when empty or invalid
+func Int64OrDefault(value string, defaultValue int64) int64 {
+	if len(value) == 0 {
+		return defaultValue
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Print("Invalid config value: ", value)
+		return defaultValue
+	}
+	return n
+}
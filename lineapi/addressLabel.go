@@ -0,0 +1,166 @@
+package lineapi
+
+import (
+	"strings"
+
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+)
+
+// labelColor is a color to tell registered addresses apart. Text messages
+// have no colors, so it is shown as a colored mark.
+type labelColor struct {
+	Name    string
+	Mark    string
+	Aliases []string
+}
+
+// labelColors are the colors accepted by "/color"
+var labelColors = []labelColor{
+	{Name: "red", Mark: "🔴", Aliases: []string{"赤"}},
+	{Name: "orange", Mark: "🟠", Aliases: []string{"オレンジ", "橙"}},
+	{Name: "yellow", Mark: "🟡", Aliases: []string{"黄", "黄色"}},
+	{Name: "green", Mark: "🟢", Aliases: []string{"緑"}},
+	{Name: "blue", Mark: "🔵", Aliases: []string{"青"}},
+	{Name: "purple", Mark: "🟣", Aliases: []string{"紫"}},
+	{Name: "brown", Mark: "🟤", Aliases: []string{"茶", "茶色"}},
+	{Name: "black", Mark: "⚫", Aliases: []string{"黒"}},
+	{Name: "white", Mark: "⚪", Aliases: []string{"白"}},
+}
+
+// clearWord removes a label, emoji or color
+const clearWord = "-"
+
+const (
+	// maxLabelLength keeps labels short enough for notification titles
+	maxLabelLength = 20
+	// maxEmojiLength allows an emoji made of several code points
+	maxEmojiLength = 8
+)
+
+// findLabelColor returns the color named name in any language, or nil
+func findLabelColor(name string) *labelColor {
+	name = strings.TrimSpace(name)
+	for i := range labelColors {
+		color := &labelColors[i]
+		if strings.EqualFold(name, color.Name) || name == color.Mark {
+			return color
+		}
+		for _, alias := range color.Aliases {
+			if name == alias {
+				return color
+			}
+		}
+	}
+	return nil
+}
+
+// accountMark returns the marks of a color and an emoji followed by a space,
+// or "" when neither is set
+func accountMark(color string, emoji string) string {
+	mark := emoji
+	if labelColor := findLabelColor(color); labelColor != nil {
+		mark = labelColor.Mark + mark
+	}
+	if len(mark) == 0 {
+		return ""
+	}
+	return mark + " "
+}
+
+// addressDisplayName shows a registered address with its marks and label
+func addressDisplayName(registeredAddress mongodb.RegisteredAddress) string {
+	name := registeredAddress.Address
+	if len(registeredAddress.Label) > 0 {
+		name = registeredAddress.Label + " <" + registeredAddress.Address + ">"
+	}
+	return accountMark(registeredAddress.Color, registeredAddress.Emoji) + name
+}
+
+// addressArgument takes the registered address from the first argument. It
+// may be omitted when only one address is registered.
+func addressArgument(ctx commandContext) (*mongodb.RegisteredAddress, []string) {
+	configVars := helper.ConfigVars()
+	lineUser := mongodb.ReadLineUser(ctx.LineID, configVars.MongodbURI)
+	if len(ctx.Args) > 0 {
+		if address := registeredAddressOf(ctx.LineID, ctx.Args[0]); len(address) > 0 {
+			return lineUser.FindRegisteredAddress(address), ctx.Args[1:]
+		}
+	}
+	if len(lineUser.RegisteredAddresses) == 1 {
+		return &lineUser.RegisteredAddresses[0], ctx.Args
+	}
+	return nil, ctx.Args
+}
+
+// updateAddressLabel stores the label of registeredAddress and replies with the result
+func updateAddressLabel(ctx commandContext, registeredAddress mongodb.RegisteredAddress) {
+	configVars := helper.ConfigVars()
+	a := registeredAddress
+	if !mongodb.UpdateRegisteredAddressLabel(ctx.LineID, a.Address, a.Label, a.Emoji, a.Color, configVars.MongodbURI) {
+		replyText(ctx, renderTemplate(ctx.Language, "address_not_registered.txt", templateData{"Address": a.Address}))
+		return
+	}
+	replyText(ctx, renderTemplate(ctx.Language, "address_label_changed.txt", templateData{"Name": addressDisplayName(a)}))
+}
+
+// labelUsage replies how to use a labeling command
+func labelUsage(ctx commandContext, name string, usage string) {
+	var colors []string
+	for _, color := range labelColors {
+		colors = append(colors, color.Mark+" "+color.Name)
+	}
+	replyText(ctx, renderTemplate(ctx.Language, "address_label_usage.txt", templateData{
+		"Command": commandPrefix + name + " " + usage,
+		"Clear":   clearWord,
+		"Colors":  colors,
+		"IsColor": name == "color",
+	}))
+}
+
+// labelAddress is the "/label [address] <label>" command
+func labelAddress(ctx commandContext) {
+	registeredAddress, args := addressArgument(ctx)
+	if registeredAddress == nil || len(args) == 0 {
+		labelUsage(ctx, "label", "[address] <label>")
+		return
+	}
+	registeredAddress.Label = ""
+	if label := strings.Join(args, " "); label != clearWord {
+		registeredAddress.Label = truncateText(label, maxLabelLength)
+	}
+	updateAddressLabel(ctx, *registeredAddress)
+}
+
+// emojiAddress is the "/emoji [address] <emoji>" command
+func emojiAddress(ctx commandContext) {
+	registeredAddress, args := addressArgument(ctx)
+	if registeredAddress == nil || len(args) != 1 {
+		labelUsage(ctx, "emoji", "[address] <emoji>")
+		return
+	}
+	registeredAddress.Emoji = ""
+	if args[0] != clearWord {
+		registeredAddress.Emoji = truncateText(args[0], maxEmojiLength)
+	}
+	updateAddressLabel(ctx, *registeredAddress)
+}
+
+// colorAddress is the "/color [address] <color>" command
+func colorAddress(ctx commandContext) {
+	registeredAddress, args := addressArgument(ctx)
+	if registeredAddress == nil || len(args) != 1 {
+		labelUsage(ctx, "color", "[address] <color>")
+		return
+	}
+	registeredAddress.Color = ""
+	if args[0] != clearWord {
+		color := findLabelColor(args[0])
+		if color == nil {
+			labelUsage(ctx, "color", "[address] <color>")
+			return
+		}
+		registeredAddress.Color = color.Name
+	}
+	updateAddressLabel(ctx, *registeredAddress)
+}
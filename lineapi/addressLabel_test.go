@@ -0,0 +1,78 @@
+package lineapi
+
+import (
+	"testing"
+
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+)
+
+func TestFindLabelColor(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "red", want: "red"},
+		{name: " Blue ", want: "blue"},
+		{name: "GREEN", want: "green"},
+		{name: "🟣", want: "purple"},
+		{name: "赤", want: "red"},
+		{name: "黄色", want: "yellow"},
+		{name: "橙", want: "orange"},
+		{name: "ultraviolet", want: ""},
+		{name: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if color := findLabelColor(tt.name); color != nil {
+				got = color.Name
+			}
+			if got != tt.want {
+				t.Errorf("findLabelColor(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccountMark(t *testing.T) {
+	tests := []struct {
+		name  string
+		color string
+		emoji string
+		want  string
+	}{
+		{name: "none", want: ""},
+		{name: "color", color: "red", want: "🔴 "},
+		{name: "color alias", color: "青", want: "🔵 "},
+		{name: "emoji", emoji: "💼", want: "💼 "},
+		{name: "color and emoji", color: "green", emoji: "🏠", want: "🟢🏠 "},
+		{name: "unknown color", color: "ultraviolet", emoji: "🏠", want: "🏠 "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := accountMark(tt.color, tt.emoji); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddressDisplayName(t *testing.T) {
+	tests := []struct {
+		name    string
+		address mongodb.RegisteredAddress
+		want    string
+	}{
+		{name: "address only", address: mongodb.RegisteredAddress{Address: "taro@example.com"}, want: "taro@example.com"},
+		{name: "label", address: mongodb.RegisteredAddress{Address: "taro@example.com", Label: "Work"}, want: "Work <taro@example.com>"},
+		{name: "label and marks", address: mongodb.RegisteredAddress{Address: "taro@example.com", Label: "Work", Color: "blue", Emoji: "💼"}, want: "🔵💼 Work <taro@example.com>"},
+		{name: "marks only", address: mongodb.RegisteredAddress{Address: "*@example.org", Color: "赤"}, want: "🔴 *@example.org"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := addressDisplayName(tt.address); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
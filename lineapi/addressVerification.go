@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"errors"
 	"net/mail"
+	"strings"
 	"time"
 
 	"github.com/mshrtsr/mail-notice-linebot/helper"
@@ -11,6 +12,37 @@ import (
 	"github.com/mshrtsr/mail-notice-linebot/mongodb"
 )
 
+// verificationCodeTTL is how long a verification code is valid
+const verificationCodeTTL = 5 * time.Minute
+
+// validateAddress parses and normalizes an entered address. It returns the
+// template telling why the address is rejected, or "" when it is accepted.
+// entered are the addresses already accepted in the same session.
+func validateAddress(lineID string, text string, entered []string) (string, string) {
+	configVars := helper.ConfigVars()
+	lineUser := mongodb.ReadLineUser(lineID, configVars.MongodbURI)
+	return validateAddressFor(lineUser, text, entered, mailmanager.CurrentAddressMatchOptions())
+}
+
+// validateAddressFor is validateAddress for an already loaded lineUser
+func validateAddressFor(lineUser mongodb.LineUser, text string, entered []string, opts mailmanager.AddressMatchOptions) (string, string) {
+	text = strings.TrimSpace(text)
+	parsed, err := mail.ParseAddress(text)
+	if err != nil || strings.ContainsAny(parsed.Address, " \t\r\n") {
+		return text, "address_invalid.txt"
+	}
+	address := mailmanager.NormalizeAddress(parsed.Address, opts)
+	for _, enteredAddress := range entered {
+		if enteredAddress == address {
+			return address, "address_duplicate.txt"
+		}
+	}
+	if lineUser.FindRegisteredAddress(address) != nil {
+		return address, "address_already_registered.txt"
+	}
+	return address, ""
+}
+
 // GenerateVerificationCode ..
 func GenerateVerificationCode(lineID string, address string) string {
 	configVars := helper.ConfigVars()
@@ -29,40 +61,51 @@ func GenerateVerificationCode(lineID string, address string) string {
 // VerifyAddress ..
 func VerifyAddress(lineID string, verificationCode string) (string, error) {
 	configVars := helper.ConfigVars()
+	language := userLanguage(lineID)
 
 	verificationCodeHash := sha256.Sum256([]byte(verificationCode))
 	verificationPendingAddress := mongodb.ReadVerificationPendingAddress(string(verificationCodeHash[:]), configVars.MongodbURI)
 	if verificationPendingAddress.LineID != lineID {
-		return "", errors.New("無効な確認コードです")
+		return "", errors.New(renderTemplate(language, "verification_code_invalid.txt", nil))
 	}
 	if verificationPendingAddress.VerificationCodeHash != string(verificationCodeHash[:]) {
-		return "", errors.New("無効な確認コードです")
+		return "", errors.New(renderTemplate(language, "verification_code_invalid.txt", nil))
 	}
-	if time.Now().Sub(verificationPendingAddress.CreatedAt) > time.Minute*5 {
+	if time.Now().Sub(verificationPendingAddress.CreatedAt) > verificationCodeTTL {
 		mongodb.DeleteVerificationPendingAddress(lineID, string(verificationCodeHash[:]), configVars.MongodbURI)
-		return "", errors.New("確認コードの有効期限が切れました")
+		return "", errors.New(renderTemplate(language, "verification_code_expired.txt", nil))
 	}
 
 	lineUser := mongodb.ReadLineUser(lineID, configVars.MongodbURI)
 	if len(lineUser.LineID) == 0 {
 		lineUser.LineID = lineID
 	}
-	lineUser.RegisteredAddresses = append(lineUser.RegisteredAddresses, verificationPendingAddress.Address)
+	address := mailmanager.NormalizeAddress(verificationPendingAddress.Address, mailmanager.CurrentAddressMatchOptions())
+	if lineUser.FindRegisteredAddress(address) == nil {
+		lineUser.RegisteredAddresses = append(lineUser.RegisteredAddresses, mongodb.RegisteredAddress{Address: address})
+	}
 	mongodb.DeleteVerificationPendingAddress(lineID, string(verificationCodeHash[:]), configVars.MongodbURI)
 	mongodb.CreateOrUpdateLineUser(lineUser, configVars.MongodbURI)
-	return verificationPendingAddress.Address, nil
+	return address, nil
 
 }
 
 // SendVerificationMail ..
-func SendVerificationMail(userName, userAddress, verificationKey string) {
+func SendVerificationMail(language, userName, userAddress, verificationKey string) error {
 	configVars := helper.ConfigVars()
 	from := mail.Address{Name: configVars.SMTP.SenderUsername, Address: configVars.SMTP.SenderAddress}
 	to := mail.Address{Name: userName, Address: userAddress}
-	subject := "LINEBOT: メールお知らせくん登録確認"
-	body := "この度はメールお知らせくんのご利用ありがとうございます。\n LINEの戻って以下の確認コードを送信してください。\n 確認コード：" + verificationKey
-	smptServerName := configVars.SMTP.ServerName
-	smtpAuthUser := configVars.SMTP.AuthUser
-	smtpAuthPassword := configVars.SMTP.AuthPassword
-	mailmanager.SendMail(from, to, subject, body, smptServerName, smtpAuthUser, smtpAuthPassword)
+	data := templateData{"Code": verificationKey}
+	subject := renderTemplate(language, "verification_mail_subject.txt", data)
+	body := renderTemplate(language, "verification_mail.txt", data)
+	htmlBody := renderTemplate(language, "verification_mail.html", data)
+	msg := &mailmanager.OutgoingMessage{
+		From:    from,
+		To:      []mail.Address{to},
+		Subject: subject,
+		Text:    body,
+		HTML:    htmlBody,
+		Charset: configVars.SMTP.Charset,
+	}
+	return mailmanager.CurrentMailer().Send(msg)
 }
@@ -0,0 +1,94 @@
+package lineapi
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mshrtsr/mail-notice-linebot/mailmanager"
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+)
+
+func TestSendVerificationMail(t *testing.T) {
+	os.Setenv("SENDER_ADDRESS", "bot@example.com")
+	os.Setenv("SENDER_USERNAME", "Mail Notice")
+	defer os.Unsetenv("SENDER_ADDRESS")
+	defer os.Unsetenv("SENDER_USERNAME")
+
+	tests := []struct {
+		language string
+		subject  string
+	}{
+		{language: "ja", subject: "LINEBOT: メールお知らせくん登録確認"},
+		{language: "en", subject: "LINEBOT: Mail notice registration"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			mailer := &mailmanager.RecordingMailer{}
+			mailmanager.SetMailer(mailer)
+			defer mailmanager.SetMailer(nil)
+
+			code := "VC-0123456789"
+			if err := SendVerificationMail(tt.language, "Alice", "alice@example.org", code); err != nil {
+				t.Fatal(err)
+			}
+
+			messages := mailer.Messages()
+			if len(messages) != 1 {
+				t.Fatalf("sent %d messages, want 1", len(messages))
+			}
+			msg := messages[0]
+			if msg.From.Address != "bot@example.com" || msg.From.Name != "Mail Notice" {
+				t.Errorf("From = %v", msg.From)
+			}
+			if len(msg.To) != 1 || msg.To[0].Address != "alice@example.org" || msg.To[0].Name != "Alice" {
+				t.Errorf("To = %v", msg.To)
+			}
+			if msg.Subject != tt.subject {
+				t.Errorf("Subject = %q, want %q", msg.Subject, tt.subject)
+			}
+			if !strings.Contains(msg.Text, code) {
+				t.Errorf("Text does not contain the code: %q", msg.Text)
+			}
+			if !strings.Contains(msg.HTML, code) {
+				t.Errorf("HTML does not contain the code: %q", msg.HTML)
+			}
+		})
+	}
+}
+
+func TestValidateAddressFor(t *testing.T) {
+	lineUser := mongodb.LineUser{
+		LineID:              "U1",
+		RegisteredAddresses: []mongodb.RegisteredAddress{{Address: "taro@example.com"}, {Address: "*@example.org"}},
+	}
+	opts := mailmanager.AddressMatchOptions{FoldLocalPart: true, StripSubAddress: true, GmailDots: true}
+
+	tests := []struct {
+		name          string
+		text          string
+		entered       []string
+		wantAddress   string
+		wantRejection string
+	}{
+		{name: "accepted", text: "hanako@example.com", wantAddress: "hanako@example.com"},
+		{name: "normalized", text: "  Hanako@Example.COM ", wantAddress: "hanako@example.com"},
+		{name: "display-name form", text: "Hanako <hanako@example.com>", wantAddress: "hanako@example.com"},
+		{name: "encoded display name", text: "=?UTF-8?B?6Iqx5a2Q?= <hanako@example.com>", wantAddress: "hanako@example.com"},
+		{name: "wildcard", text: "*@example.net", wantAddress: "*@example.net"},
+		{name: "no domain", text: "hanako", wantAddress: "hanako", wantRejection: "address_invalid.txt"},
+		{name: "two addresses", text: "a@example.com, b@example.com", wantAddress: "a@example.com, b@example.com", wantRejection: "address_invalid.txt"},
+		{name: "empty", text: " ", wantAddress: "", wantRejection: "address_invalid.txt"},
+		{name: "duplicate within the session", text: "Hanako@example.com", entered: []string{"hanako@example.com"}, wantAddress: "hanako@example.com", wantRejection: "address_duplicate.txt"},
+		{name: "already registered", text: "TARO@example.com", wantAddress: "taro@example.com", wantRejection: "address_already_registered.txt"},
+		{name: "wildcard already registered", text: "*@Example.org", wantAddress: "*@example.org", wantRejection: "address_already_registered.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, rejection := validateAddressFor(lineUser, tt.text, tt.entered, opts)
+			if address != tt.wantAddress || rejection != tt.wantRejection {
+				t.Errorf("got (%q, %q), want (%q, %q)", address, rejection, tt.wantAddress, tt.wantRejection)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package lineapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+	"github.com/mshrtsr/mail-notice-linebot/mailmanager"
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+const (
+	// ContentPath is where attachments are served for LINE to download
+	ContentPath      = "/content/"
+	contentURLExpiry = time.Hour
+	// previewQuery asks ContentHandler for the preview of an image
+	previewQuery = "preview=1"
+)
+
+// forwardableAttachments returns attachments which can be sent to LINE
+func forwardableAttachments(mailObject mailmanager.MailObject) []mailmanager.Attachment {
+	configVars := helper.ConfigVars()
+	if len(configVars.BaseURL()) == 0 {
+		return nil
+	}
+	maxBytes := mailmanager.AttachmentMaxBytes()
+
+	var attachments []mailmanager.Attachment
+	for _, attachment := range mailObject.Attachments {
+		if attachment.Forwardable(maxBytes) {
+			attachments = append(attachments, attachment)
+		}
+	}
+	return attachments
+}
+
+// attachmentPostbackData ..
+func attachmentPostbackData(mailID, part string) string {
+	return "attachment=" + mailID + "&part=" + part
+}
+
+// SendAttachment replies with an attachment of an archived mail
+func SendAttachment(bot *linebot.Client, replyToken string, lineID string, mailID string, part string) {
+	configVars := helper.ConfigVars()
+	language := userLanguage(lineID)
+
+	var message linebot.SendingMessage
+	mailArchive := mongodb.ReadMailArchive(mailID, configVars.MongodbURI)
+	if !mailArchiveReadableBy(mailArchive, lineID) || len(mailArchive.Raw) == 0 {
+		message = linebot.NewTextMessage(renderTemplate(language, "attachment_expired.txt", nil))
+	} else if content, attachment, err := mailmanager.ReadAttachment(mailArchive.Raw, part); err != nil {
+		log.Print(err)
+		message = linebot.NewTextMessage(renderTemplate(language, "attachment_not_found.txt", nil))
+	} else if !attachment.Forwardable(mailmanager.AttachmentMaxBytes()) {
+		message = linebot.NewTextMessage(renderTemplate(language, "attachment_not_forwardable.txt", nil))
+	} else {
+		contentURL := signedContentURL(mailID, part, time.Now().Add(contentURLExpiry))
+		if attachment.IsImage() && imagePreviewAvailable(content) {
+			message = linebot.NewImageMessage(contentURL, contentURL+"&"+previewQuery)
+		} else {
+			// LINE bots cannot send files, so the file is offered as a link
+			altText := renderTemplate(language, "attachment_alt_text.txt", templateData{"Name": attachment.Name})
+			template := linebot.NewButtonsTemplate("", "", truncateText(altText, 160),
+				linebot.NewURIAction(renderTemplate(language, "label_open.txt", nil), contentURL))
+			message = linebot.NewTemplateMessage(altText, template)
+		}
+	}
+
+	// Send messages
+	if _, err := bot.ReplyMessage(replyToken, message).Do(); err != nil {
+		log.Print(err)
+	}
+}
+
+// imagePreviewAvailable reports whether ContentHandler can serve a preview of
+// the image. Otherwise the image is sent as a link.
+func imagePreviewAvailable(content []byte) bool {
+	preview, err := imagePreview(content)
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	return len(preview) <= previewMaxBytes
+}
+
+// mailArchiveReadableBy reports whether the mail was notified to lineID
+func mailArchiveReadableBy(mailArchive mongodb.MailArchive, lineID string) bool {
+	for _, id := range mailArchive.LineIDs {
+		if id == lineID {
+			return true
+		}
+	}
+	return false
+}
+
+// contentSignature signs a content URL with the channel secret
+func contentSignature(mailID, part string, expires int64) string {
+	configVars := helper.ConfigVars()
+	mac := hmac.New(sha256.New, []byte(configVars.LineAPI.ChannelSecret))
+	mac.Write([]byte(mailID + "\n" + part + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func signedContentURL(mailID, part string, expires time.Time) string {
+	configVars := helper.ConfigVars()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", contentSignature(mailID, part, expires.Unix()))
+	return configVars.BaseURL() + ContentPath + mailID + "/" + part + "?" + query.Encode()
+}
+
+// ContentHandler serves attachments through signed URLs: /content/<mailID>/<part>
+func ContentHandler(w http.ResponseWriter, r *http.Request) {
+	configVars := helper.ConfigVars()
+
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, ContentPath), "/")
+	if len(path) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	mailID, part := path[0], path[1]
+
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	signature := r.URL.Query().Get("signature")
+	if !hmac.Equal([]byte(signature), []byte(contentSignature(mailID, part, expires))) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	mailArchive := mongodb.ReadMailArchive(mailID, configVars.MongodbURI)
+	if len(mailArchive.Raw) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	content, attachment, err := mailmanager.ReadAttachment(mailArchive.Raw, part)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	mimeType := attachment.MIMEType
+	if r.URL.Query().Get("preview") == "1" && attachment.IsImage() {
+		if content, err = imagePreview(content); err != nil {
+			log.Print(err)
+			http.NotFound(w, r)
+			return
+		}
+		mimeType = "image/jpeg"
+	}
+
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	if _, err := w.Write(content); err != nil {
+		log.Print(err)
+	}
+}
+
+// truncateText cuts text to at most max runes
+func truncateText(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-1]) + "…"
+}
@@ -0,0 +1,186 @@
+package lineapi
+
+import (
+	"log"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+// commandPrefix starts an explicit command such as "/help"
+const commandPrefix = "/"
+
+// commandContext is passed to command handlers
+type commandContext struct {
+	Bot        *linebot.Client
+	ReplyToken string
+	LineID     string
+	Language   string
+	Args       []string
+}
+
+// command is a chat command. It is called by "/<Name>" or by any of its
+// localized aliases in keywords[<language>][<Name>].
+type command struct {
+	Name string
+	// Usage describes the arguments in help, e.g. "[address...]"
+	Usage   string
+	Handler func(ctx commandContext)
+}
+
+// commands are listed in help in this order
+var commands []command
+
+func init() {
+	commands = []command{
+		{Name: "help", Handler: sendHelp},
+		{Name: "status", Handler: func(ctx commandContext) {
+			SendConfirmSetupForwarding(ctx.Bot, ctx.ReplyToken, ctx.LineID)
+		}},
+		{Name: "list", Handler: sendAddressList},
+		{Name: "add", Usage: "[address...]", Handler: addAddresses},
+		{Name: "remove", Usage: "[address...]", Handler: removeAddresses},
+		{Name: "mute", Handler: func(ctx commandContext) { muteNotification(ctx, true) }},
+		{Name: "unmute", Handler: func(ctx commandContext) { muteNotification(ctx, false) }},
+		{Name: "snooze", Usage: "[address]", Handler: snoozeAddress},
+		{Name: "resume", Usage: "[address]", Handler: resumeAddress},
+		{Name: "label", Usage: "[address] <label>", Handler: labelAddress},
+		{Name: "emoji", Usage: "[address] <emoji>", Handler: emojiAddress},
+		{Name: "color", Usage: "[address] <color>", Handler: colorAddress},
+		{Name: "language", Usage: "[code]", Handler: changeLanguage},
+		{Name: "cancel", Handler: cancelConversation},
+	}
+}
+
+// parseCommand finds the command of text and its arguments. It returns nil
+// when text is not a command. Commands start with "/" and may take arguments;
+// a localized keyword is a command only when it is the whole message.
+func parseCommand(text string) (*command, []string) {
+	text = trimCommandText(text)
+
+	for i := range commands {
+		cmd := &commands[i]
+		if strings.HasPrefix(text, commandPrefix) {
+			if args, ok := matchCommandName(text, commandPrefix+cmd.Name); ok {
+				return cmd, args
+			}
+			continue
+		}
+		if isKeyword(text, cmd.Name) {
+			return cmd, nil
+		}
+	}
+	return nil, nil
+}
+
+// isCommand reports whether HandleCommand handles text, i.e. text is a
+// command or starts with "/"
+func isCommand(text string) bool {
+	cmd, _ := parseCommand(text)
+	return cmd != nil || strings.HasPrefix(trimCommandText(text), commandPrefix)
+}
+
+// trimCommandText trims text and replaces a leading full-width slash
+func trimCommandText(text string) string {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "／") {
+		text = commandPrefix + strings.TrimPrefix(text, "／")
+	}
+	return text
+}
+
+// matchCommandName matches name as a whole word at the beginning of text
+func matchCommandName(text, name string) ([]string, bool) {
+	if len(text) < len(name) || !strings.EqualFold(text[:len(name)], name) {
+		return nil, false
+	}
+	rest := text[len(name):]
+	if len(rest) == 0 {
+		return nil, true
+	}
+	if r, _ := utf8.DecodeRuneInString(rest); !unicode.IsSpace(r) {
+		return nil, false
+	}
+	return strings.Fields(rest), true
+}
+
+// HandleCommand runs the command in text. It returns false when text is not a command.
+func HandleCommand(bot *linebot.Client, replyToken string, lineID string, text string) bool {
+	ctx := commandContext{
+		Bot:        bot,
+		ReplyToken: replyToken,
+		LineID:     lineID,
+	}
+
+	cmd, args := parseCommand(text)
+	if cmd == nil {
+		trimmed := trimCommandText(text)
+		if !strings.HasPrefix(trimmed, commandPrefix) {
+			return false
+		}
+		// Unknown "/..." command
+		ctx.Language = userLanguage(lineID)
+		replyText(ctx, renderTemplate(ctx.Language, "unknown_command.txt", templateData{"Command": strings.Fields(trimmed)[0]}))
+		return true
+	}
+
+	ctx.Language = userLanguage(lineID)
+	ctx.Args = args
+	cmd.Handler(ctx)
+	return true
+}
+
+// findCommand returns the command named name, or nil
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].Name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// HandleCommandPostback runs the command of a "command=<name>" postback such
+// as a rich menu area. It returns false when the postback is not a command.
+func HandleCommandPostback(bot *linebot.Client, replyToken string, lineID string, query url.Values) bool {
+	cmd := findCommand(query.Get(richMenuCommandKey))
+	if cmd == nil {
+		return false
+	}
+	cmd.Handler(commandContext{
+		Bot:        bot,
+		ReplyToken: replyToken,
+		LineID:     lineID,
+		Language:   userLanguage(lineID),
+	})
+	return true
+}
+
+// replyText replies with a text message and quick replies for the next step
+func replyText(ctx commandContext, text string) {
+	message := withQuickReplies(ctx.LineID, ctx.Language, linebot.NewTextMessage(text))
+	// Send messages
+	if _, err := ctx.Bot.ReplyMessage(ctx.ReplyToken, message).Do(); err != nil {
+		log.Print(err)
+	}
+}
+
+// sendHelp lists the commands with their descriptions and aliases
+func sendHelp(ctx commandContext) {
+	lines := []string{renderTemplate(ctx.Language, "help_header.txt", nil)}
+	for _, cmd := range commands {
+		line := commandPrefix + cmd.Name
+		if len(cmd.Usage) > 0 {
+			line += " " + cmd.Usage
+		}
+		if aliases := keywords[ctx.Language][cmd.Name]; len(aliases) > 0 {
+			line += " (" + strings.Join(aliases, ", ") + ")"
+		}
+		line += "\n  " + renderTemplate(ctx.Language, "help_"+cmd.Name+".txt", nil)
+		lines = append(lines, line)
+	}
+	replyText(ctx, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,45 @@
+package lineapi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		text string
+		name string
+		args []string
+	}{
+		{text: "/help", name: "help"},
+		{text: "  /HELP  ", name: "help"},
+		{text: "／help", name: "help"},
+		{text: "/add a@example.com b@example.com", name: "add", args: []string{"a@example.com", "b@example.com"}},
+		{text: "/helpme", name: ""},
+		{text: "/unknown", name: ""},
+		{text: "help", name: "help"},
+		{text: " Status ", name: "status"},
+		{text: "ヘルプ", name: "help"},
+		{text: "ミュート解除", name: "unmute"},
+		{text: "help me", name: ""},
+		{text: "status?", name: ""},
+		{text: "add a@example.com", name: ""},
+		{text: "I need help", name: ""},
+		{text: "", name: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			cmd, args := parseCommand(tt.text)
+			name := ""
+			if cmd != nil {
+				name = cmd.Name
+			}
+			if name != tt.name {
+				t.Fatalf("command = %q, want %q", name, tt.name)
+			}
+			if strings.Join(args, " ") != strings.Join(tt.args, " ") {
+				t.Errorf("args = %q, want %q", args, tt.args)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package lineapi
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+// Conversation states
+const (
+	stateSetupAddresses = "setup.addresses"
+	stateVerifyCode     = "verify.code"
+	stateRevokeConfirm  = "revoke.confirm"
+	stateRemovePick     = "remove.pick"
+	stateRemoveConfirm  = "remove.confirm"
+	stateSnoozePick     = "snooze.pick"
+	stateSnoozeDuration = "snooze.duration"
+	stateReplyText      = "reply.text"
+	stateReplyConfirm   = "reply.confirm"
+)
+
+// conversationState is a named step of a multi-step flow
+type conversationState struct {
+	Name    string
+	Timeout time.Duration
+	// OnText handles text in this state. It returns false to handle the text as usual.
+	OnText func(ctx commandContext, conv *conversation, text string) bool
+	// OnPostback handles postback data in this state. It returns false when
+	// the postback does not belong to this state.
+	OnPostback func(ctx commandContext, conv *conversation, query url.Values) bool
+	// CancelTemplate is replied when the conversation is canceled
+	CancelTemplate string
+}
+
+// conversationStates are the registered states by name
+var conversationStates = make(map[string]*conversationState)
+
+// conversationPostbackKeys are postback keys only valid in a conversation
+var conversationPostbackKeys = []string{"revoke", "reply_send", "remove_address", "remove_all", "remove_confirm", "snooze_address", "snooze"}
+
+func init() {
+	for _, state := range []*conversationState{
+		{
+			Name:           stateSetupAddresses,
+			Timeout:        10 * time.Minute,
+			OnText:         onSetupAddressText,
+			CancelTemplate: "configure_canceled.txt",
+		}, {
+			Name:           stateVerifyCode,
+			Timeout:        verificationCodeTTL,
+			OnText:         onVerificationCodeText,
+			CancelTemplate: "canceled.txt",
+		}, {
+			Name:           stateRevokeConfirm,
+			Timeout:        5 * time.Minute,
+			OnPostback:     onRevokeConfirmPostback,
+			CancelTemplate: "canceled.txt",
+		}, {
+			Name:           stateRemovePick,
+			Timeout:        5 * time.Minute,
+			OnText:         onRemovePickText,
+			OnPostback:     onRemovePickPostback,
+			CancelTemplate: "canceled.txt",
+		}, {
+			Name:           stateRemoveConfirm,
+			Timeout:        5 * time.Minute,
+			OnPostback:     onRemoveConfirmPostback,
+			CancelTemplate: "canceled.txt",
+		}, {
+			Name:           stateSnoozePick,
+			Timeout:        5 * time.Minute,
+			OnText:         onSnoozePickText,
+			OnPostback:     onSnoozePickPostback,
+			CancelTemplate: "canceled.txt",
+		}, {
+			Name:           stateSnoozeDuration,
+			Timeout:        5 * time.Minute,
+			OnText:         onSnoozeDurationText,
+			OnPostback:     onSnoozeDurationPostback,
+			CancelTemplate: "canceled.txt",
+		}, {
+			Name:           stateReplyText,
+			Timeout:        30 * time.Minute,
+			OnText:         onReplyText,
+			CancelTemplate: "reply_canceled.txt",
+		}, {
+			Name:           stateReplyConfirm,
+			Timeout:        30 * time.Minute,
+			OnPostback:     onReplyConfirmPostback,
+			CancelTemplate: "reply_canceled.txt",
+		},
+	} {
+		conversationStates[state.Name] = state
+	}
+}
+
+// conversation is the current state of a chat
+type conversation struct {
+	mongodb.ConversationState
+}
+
+// readConversation returns the active conversation of lineID, or nil
+func readConversation(lineID string) *conversation {
+	configVars := helper.ConfigVars()
+	record := mongodb.ReadConversationState(lineID, configVars.MongodbURI)
+	if record.LineID != lineID || conversationStates[record.State] == nil {
+		return nil
+	}
+	if time.Now().After(record.ExpiresAt) {
+		mongodb.DeleteConversationState(lineID, configVars.MongodbURI)
+		return nil
+	}
+	return &conversation{record}
+}
+
+// startConversation replaces the conversation of lineID with a new one in state
+func startConversation(lineID string, state string, values map[string][]string) *conversation {
+	if values == nil {
+		values = make(map[string][]string)
+	}
+	conv := &conversation{mongodb.ConversationState{LineID: lineID, Values: values}}
+	conv.transition(state)
+	return conv
+}
+
+// endConversation ends the conversation of lineID if any
+func endConversation(lineID string) {
+	configVars := helper.ConfigVars()
+	mongodb.DeleteConversationState(lineID, configVars.MongodbURI)
+}
+
+// transition moves to state and restarts its timeout
+func (c *conversation) transition(state string) {
+	configVars := helper.ConfigVars()
+	c.State = state
+	c.UpdatedAt = time.Now()
+	c.ExpiresAt = c.UpdatedAt.Add(conversationStates[state].Timeout)
+	mongodb.CreateOrUpdateConversationState(c.ConversationState, configVars.MongodbURI)
+}
+
+// save stores changed values without restarting the timeout
+func (c *conversation) save() {
+	configVars := helper.ConfigVars()
+	c.UpdatedAt = time.Now()
+	mongodb.CreateOrUpdateConversationState(c.ConversationState, configVars.MongodbURI)
+}
+
+// end ends the conversation
+func (c *conversation) end() {
+	endConversation(c.LineID)
+}
+
+// get returns the first value of key
+func (c *conversation) get(key string) string {
+	if values := c.Values[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// set replaces the values of key
+func (c *conversation) set(key string, value string) {
+	c.Values[key] = []string{value}
+}
+
+// HandleConversationText passes text to the active conversation of lineID.
+// It returns false when the text should be handled as usual.
+func HandleConversationText(bot *linebot.Client, replyToken string, lineID string, text string) bool {
+	conv := readConversation(lineID)
+	if conv == nil {
+		return false
+	}
+	ctx := commandContext{
+		Bot:        bot,
+		ReplyToken: replyToken,
+		LineID:     lineID,
+		Language:   userLanguage(lineID),
+	}
+	state := conversationStates[conv.State]
+
+	// Any conversation can be canceled
+	if cmd, _ := parseCommand(text); cmd != nil && cmd.Name == "cancel" {
+		conv.end()
+		replyText(ctx, renderTemplate(ctx.Language, state.CancelTemplate, nil))
+		return true
+	}
+	if state.OnText == nil {
+		return false
+	}
+	return state.OnText(ctx, conv, text)
+}
+
+// HandleConversationPostback passes postback data to the active conversation
+// of lineID. It returns false when the postback does not belong to a conversation.
+func HandleConversationPostback(bot *linebot.Client, replyToken string, lineID string, query url.Values) bool {
+	ctx := commandContext{
+		Bot:        bot,
+		ReplyToken: replyToken,
+		LineID:     lineID,
+	}
+
+	if conv := readConversation(lineID); conv != nil {
+		state := conversationStates[conv.State]
+		if state.OnPostback != nil {
+			ctx.Language = userLanguage(lineID)
+			if state.OnPostback(ctx, conv, query) {
+				return true
+			}
+		}
+	}
+
+	// Buttons of a finished or timed out conversation
+	for _, key := range conversationPostbackKeys {
+		if len(query.Get(key)) > 0 {
+			ctx.Language = userLanguage(lineID)
+			replyText(ctx, renderTemplate(ctx.Language, "conversation_expired.txt", nil))
+			return true
+		}
+	}
+	return false
+}
+
+// cancelConversation is the "/cancel" command outside of a conversation
+func cancelConversation(ctx commandContext) {
+	replyText(ctx, renderTemplate(ctx.Language, "nothing_to_cancel.txt", nil))
+}
@@ -0,0 +1,8 @@
+package lineapi
+
+// defaultTemplates are the built-in catalogs by language. They are used unless
+// TEMPLATE_DIR has a file of the same name.
+var defaultTemplates = map[string]map[string]string{
+	"ja": jaTemplates,
+	"en": enTemplates,
+}
@@ -0,0 +1,192 @@
+package lineapi
+
+// enTemplates ..
+var enTemplates = map[string]string{
+	// Buttons
+	"label_yes.txt":            "Yes",
+	"label_no.txt":             "No",
+	"label_read_body.txt":      "Read",
+	"label_reply.txt":          "Reply",
+	"label_open.txt":           "Open",
+	"label_send.txt":           "Send",
+	"label_cancel.txt":         "Cancel",
+	"label_finish.txt":         "Done",
+	"label_command_add.txt":    "Set up",
+	"label_command_list.txt":   "List",
+	"label_command_remove.txt": "Remove",
+	"label_command_mute.txt":   "Mute",
+	"label_command_unmute.txt": "Unmute",
+	"label_command_help.txt":   "Help",
+	"label_remove_all.txt":     "Remove all",
+	"label_snooze_1h.txt":      "1 hour",
+	"label_snooze_today.txt":   "Today",
+	"label_snooze_forever.txt": "Until resumed",
+
+	// Setup and revoke
+	"introduction.txt": `Thanks for adding me! I'm the mail notice bot.
+I'll let you know when mail arrives at your registered addresses.`,
+	"registered_addresses.txt": `Hi! This is the mail notice bot.
+{{if .Addresses}}You get notices for these addresses:
+{{join .Addresses "\n"}}{{else}}You have no addresses registered for notices.{{end}}{{if .Muted}}
+Notices are paused.{{end}}`,
+	"confirm_setup.txt":  `{{if .Addresses}}Set up mail notices again?{{else}}Set up mail notices?{{end}}`,
+	"confirm_revoke.txt": "Stop mail notices?",
+	"revoked.txt":        "Your notice settings have been deleted!",
+	"remove_choose.txt": `Choose the address to stop notices for{{if gt .More 0}}
+Send "{{.Command}} <address>" for the other {{.More}} address(es){{end}}`,
+	"confirm_remove.txt": "Stop notices for {{.Address}}?",
+	// random_reply.txt has one reply per line
+	"random_reply.txt": `Sorry, I didn't get that!
+Say "mail notice" to check your notice settings
+Say "stop notice" to stop mail notices
+Probably no new mail!
+Send "/help" to see the commands`,
+	"configure_already_started.txt": `You are already setting up.
+Send "." to finish`,
+	"configure_started.txt": `Send your mail addresses one per message.
+Send "." to finish`,
+	"configure_finished.txt": `{{if .Sent}}A verification code was sent to {{len .Sent}} address(es) below. Check your mail and send the code here.
+{{join .Sent "\n"}}
+{{end}}{{if .Failed}}Failed to send a verification code to the addresses below. Please try again later.
+{{join .Failed "\n"}}
+{{end}}{{if not (or .Sent .Failed)}}No address was set up{{end}}`,
+
+	// Address entry
+	"address_accepted.txt":           "Accepted {{.Address}} ({{.Count}} so far)",
+	"address_invalid.txt":            "{{.Address}} is not a valid mail address",
+	"address_duplicate.txt":          "{{.Address}} has already been entered",
+	"address_already_registered.txt": "{{.Address}} is already registered",
+
+	// Verification
+	"verification_code_invalid.txt": "Invalid verification code",
+	"verification_code_expired.txt": "The verification code has expired",
+	"address_verified.txt": `Your address has been verified
+{{.Address}}
+Forward mail to the address below to start receiving notices
+{{.ForwardingAddress}}`,
+	"verification_mail_subject.txt": "LINEBOT: Mail notice registration",
+	"verification_mail.txt": `Thank you for using the mail notice bot.
+Go back to LINE and send the verification code below.
+Verification code: {{.Code}}
+`,
+	"verification_mail.html": `<p>Thank you for using the mail notice bot.</p>
+<p>Go back to LINE and send the verification code below.</p>
+<p>Verification code: <code>{{.Code}}</code></p>
+`,
+
+	// Notification
+	"push_notification.txt": `You have {{len .Mails}} new mail(s)
+{{range $i, $mail := .Mails}}{{if gt (len $.Mails) 1}}{{inc $i}}.
+{{end}}To: {{accountMark $mail.AddressColor $mail.AddressEmoji}}{{$mail.AccountName}}
+From: {{if $mail.MailFrom}}{{$mail.MailFromDisplayName}}{{else}}(unknown){{end}}
+Subject: {{$mail.MailSubject}}
+{{range $mail.Attachments}}Attachment: {{.Name}} ({{.FormatSize}})
+{{end}}{{end}}`,
+	"mail_no_subject.txt":       "(no subject)",
+	"mail_action_text.txt":      "To: {{.Account}}\nFrom: {{.From}}",
+	"mail_body_header.txt":      "From: {{.From}}\nSubject: {{.Subject}}",
+	"mail_body_empty.txt":       "(no body)",
+	"mail_body_omitted.txt":     "(truncated)",
+	"mail_body_unavailable.txt": "The body can't be shown because the mail has expired or is too large",
+
+	// Attachments
+	"attachment_expired.txt":         "The attachment can't be sent because the mail has expired",
+	"attachment_not_found.txt":       "Attachment not found",
+	"attachment_not_forwardable.txt": "This attachment can't be sent",
+	"attachment_alt_text.txt":        "Attachment: {{.Name}}",
+
+	// Reply
+	"reply_expired.txt":     "You can't reply because the mail has expired",
+	"reply_unavailable.txt": "You can't reply to this mail",
+	"reply_prompt.txt": `Type your reply to {{.To}}
+Send "{{.CancelWord}}" to cancel`,
+	"reply_confirm.txt":  "Send this reply?",
+	"reply_nothing.txt":  "There is no reply to send",
+	"reply_sent.txt":     "Replied to {{.To}}",
+	"reply_failed.txt":   "Failed to send the reply to {{.To}}",
+	"reply_canceled.txt": "Reply canceled",
+
+	// Commands
+	"help_header.txt":     "Commands",
+	"help_help.txt":       "Show this list",
+	"help_status.txt":     "Check or set up your notice settings",
+	"help_list.txt":       "Show your registered addresses",
+	"help_add.txt":        "Add mail addresses",
+	"help_remove.txt":     "Stop notices for addresses, or choose one when omitted",
+	"help_mute.txt":       "Pause notices",
+	"help_unmute.txt":     "Resume notices",
+	"help_snooze.txt":     "Pause notices for one address",
+	"help_resume.txt":     "Resume paused notices, or all of them when omitted",
+	"help_label.txt":      "Name an address in notices. \"-\" removes it",
+	"help_emoji.txt":      "Mark an address with an emoji in notices. \"-\" removes it",
+	"help_color.txt":      "Mark an address with a color in notices. \"-\" removes it",
+	"help_language.txt":   "Change the language",
+	"help_cancel.txt":     "Cancel setup or a reply in progress",
+	"unknown_command.txt": "There is no command \"{{.Command}}\"\nSend \"/help\" to see the commands",
+	"address_list.txt": `{{if .Addresses}}Registered addresses
+{{join .Addresses "\n"}}{{else}}You have no registered addresses{{end}}{{if .Muted}}
+Notices are paused.{{end}}`,
+	"address_removed.txt": `{{if .Removed}}Notices stopped for these addresses
+{{join .Removed "\n"}}
+{{end}}{{if .NotFound}}These addresses are not registered
+{{join .NotFound "\n"}}{{end}}`,
+	"address_not_registered.txt": "{{.Address}} is not registered",
+	"address_snoozed.txt":        "{{.Address}} (paused {{if .Until}}until {{.Until}}{{else}}until resumed{{end}})",
+	"time_format.txt":            "Jan 2 15:04",
+	"snooze_choose_address.txt":  "Choose the address to pause notices for",
+	"snooze_choose_duration.txt": "How long should notices for {{.Address}} be paused?",
+	"snoozed.txt": `Notices for {{.Address}} are paused {{if .Until}}until {{.Until}}{{else}}until resumed{{end}}
+Send "/resume" to resume`,
+	"resumed.txt": `{{if .Addresses}}Notices resumed for
+{{join .Addresses "\n"}}{{else}}No address is paused{{end}}`,
+	"address_label_changed.txt": "Notices will show the address as\n{{.Name}}",
+	"address_label_usage.txt": `Send "{{.Command}}"
+The address may be omitted when only one is registered. "{{.Clear}}" removes it{{if .IsColor}}
+Colors: {{join .Colors ", "}}{{end}}`,
+	"muted.txt":   "Notices paused\nSend \"/unmute\" to resume",
+	"unmuted.txt": "Notices resumed",
+
+	// Language
+	"language_changed.txt": "Language changed to English",
+	"language_usage.txt": `Current language: {{.Language}}
+Send "{{.Command}} <code>" to change it
+Available: {{join .Languages ", "}}`,
+
+	// Conversation
+	"configure_canceled.txt":   "Address setup canceled",
+	"canceled.txt":             "Canceled",
+	"conversation_expired.txt": "This has timed out or is already finished",
+	"nothing_to_cancel.txt":    "There is nothing to cancel",
+
+	// Settings page
+	"liff_settings.html":           liffSettingsPage,
+	"liff_title.txt":               "Mail notice settings",
+	"liff_language.txt":            "Language",
+	"liff_mute_all.txt":            "Pause all notices",
+	"liff_addresses.txt":           "Addresses",
+	"liff_no_addresses.txt":        "You have no registered addresses",
+	"liff_label.txt":               "Name",
+	"liff_emoji.txt":               "Emoji",
+	"liff_color.txt":               "Color",
+	"liff_none.txt":                "None",
+	"liff_snooze.txt":              "Pause",
+	"liff_snooze_keep.txt":         "No change",
+	"liff_resume.txt":              "Resume",
+	"liff_paused.txt":              "Paused until resumed",
+	"liff_paused_until.txt":        "Paused until",
+	"liff_remove.txt":              "Stop notices",
+	"liff_save.txt":                "Save",
+	"liff_saved.txt":               "Saved",
+	"liff_add.txt":                 "Add an address",
+	"liff_quiet_hours.txt":         "Quiet hours",
+	"liff_quiet_hours_enabled.txt": "Hold notices during quiet hours and send them when they end",
+	"liff_quiet_hours_start.txt":   "From",
+	"liff_quiet_hours_end.txt":     "To",
+	"liff_filters.txt":             "Filters",
+	"liff_filters_help.txt":        "Mails containing the text are not notified",
+	"liff_filter_add.txt":          "Add a filter",
+	"liff_filter_remove.txt":       "Remove",
+	"liff_filter_from.txt":         "From",
+	"liff_filter_to.txt":           "To",
+	"liff_filter_subject.txt":      "Subject",
+}
@@ -0,0 +1,69 @@
+package lineapi
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	// Decode PNG attachments
+	_ "image/png"
+)
+
+const (
+	// previewMaxSize is the LINE limit of preview image width and height
+	previewMaxSize = 240
+	// previewMaxBytes is the LINE limit of preview image content
+	previewMaxBytes = 1 << 20
+	// previewSamples is the number of source pixels averaged per axis
+	previewSamples = 4
+)
+
+// imagePreview returns a JPEG of content scaled down to fit previewMaxSize
+func imagePreview(content []byte) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	previewWidth, previewHeight := width, height
+	if width > previewMaxSize || height > previewMaxSize {
+		if width >= height {
+			previewWidth, previewHeight = previewMaxSize, height*previewMaxSize/width
+		} else {
+			previewWidth, previewHeight = width*previewMaxSize/height, previewMaxSize
+		}
+	}
+	if previewWidth < 1 {
+		previewWidth = 1
+	}
+	if previewHeight < 1 {
+		previewHeight = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, previewWidth, previewHeight))
+	for y := 0; y < previewHeight; y++ {
+		for x := 0; x < previewWidth; x++ {
+			// Average a few pixels of the source area over a white background
+			var r, g, b, n uint32
+			for sy := 0; sy < previewSamples; sy++ {
+				for sx := 0; sx < previewSamples; sx++ {
+					px := bounds.Min.X + (x*previewSamples+sx)*width/(previewWidth*previewSamples)
+					py := bounds.Min.Y + (y*previewSamples+sy)*height/(previewHeight*previewSamples)
+					pr, pg, pb, pa := src.At(px, py).RGBA()
+					r += pr + 0xffff - pa
+					g += pg + 0xffff - pa
+					b += pb + 0xffff - pa
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: 0xffff})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
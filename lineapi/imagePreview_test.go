@@ -0,0 +1,60 @@
+package lineapi
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestImagePreview(t *testing.T) {
+	encodePNG := func(width, height int) []byte {
+		img := image.NewNRGBA(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 0x80, A: uint8(x + y)})
+			}
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name          string
+		content       []byte
+		width, height int
+		wantErr       bool
+	}{
+		{name: "landscape", content: encodePNG(1000, 500), width: 240, height: 120},
+		{name: "portrait", content: encodePNG(300, 1200), width: 60, height: 240},
+		{name: "small", content: encodePNG(100, 80), width: 100, height: 80},
+		{name: "thin", content: encodePNG(2000, 1), width: 240, height: 1},
+		{name: "not an image", content: []byte("%PDF-1.4"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preview, err := imagePreview(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(preview) > previewMaxBytes {
+				t.Errorf("preview has %d bytes", len(preview))
+			}
+			img, err := jpeg.Decode(bytes.NewReader(preview))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := img.Bounds(); got.Dx() != tt.width || got.Dy() != tt.height {
+				t.Errorf("size = %dx%d, want %dx%d", got.Dx(), got.Dy(), tt.width, tt.height)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package lineapi
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+	"github.com/mshrtsr/mail-notice-linebot/mailmanager"
+)
+
+const (
+	inboundParseMaxBytes      = 32 << 20
+	inboundParseTimestampSkew = 5 * time.Minute
+)
+
+// usedInboundTokens remembers Mailgun style tokens until their timestamps
+// leave the accepted range, so that a signed request cannot be replayed
+var (
+	usedInboundTokens   = make(map[string]time.Time)
+	usedInboundTokensMu sync.Mutex
+)
+
+// InboundParseHandler receives mail POSTed by inbound-parse providers and notifies LINE users
+func InboundParseHandler(w http.ResponseWriter, r *http.Request) {
+	configVars := helper.ConfigVars()
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, inboundParseMaxBytes))
+	if err != nil {
+		log.Print("InboundParse: ", err)
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	fields, err := readInboundFields(r)
+	if err != nil {
+		log.Print("InboundParse: ", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := verifyInboundSignature(configVars.InboundParse.SigningKey, r, body, fields); err != nil {
+		log.Print("InboundParse: ", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	msg, err := mailmanager.ParseInboundPayload(fields)
+	if err != nil {
+		log.Print("InboundParse: ", err)
+		// Providers retry on non-2xx, so malformed mail is accepted and dropped
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	NotifyMessages([]imap.Message{*msg})
+	w.WriteHeader(http.StatusOK)
+}
+
+// readInboundFields flattens a multipart, urlencoded or JSON payload into a map
+func readInboundFields(r *http.Request) (map[string]string, error) {
+	fields := make(map[string]string)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		var v map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			return nil, err
+		}
+		for key, value := range v {
+			switch value := value.(type) {
+			case string:
+				fields[key] = value
+			case nil:
+			default:
+				// Nested objects (e.g. "envelope") are kept as JSON text
+				b, err := json.Marshal(value)
+				if err != nil {
+					return nil, err
+				}
+				fields[key] = string(b)
+			}
+		}
+	case "multipart/form-data":
+		if err := r.ParseMultipartForm(inboundParseMaxBytes); err != nil {
+			return nil, err
+		}
+		for key, values := range r.MultipartForm.Value {
+			if len(values) > 0 {
+				fields[key] = values[0]
+			}
+		}
+	default:
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+		for key := range r.PostForm {
+			fields[key] = r.PostForm.Get(key)
+		}
+	}
+
+	return fields, nil
+}
+
+// verifyInboundSignature checks a Mailgun style timestamp/token/signature triple,
+// or a hex HMAC-SHA256 of the raw body given in the X-Inbound-Signature header.
+func verifyInboundSignature(signingKey string, r *http.Request, body []byte, fields map[string]string) error {
+	if len(signingKey) == 0 {
+		return errors.New("signing key is not configured")
+	}
+
+	if signature := fields["signature"]; len(signature) > 0 {
+		timestamp := fields["timestamp"]
+		sec, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return errors.New("invalid timestamp")
+		}
+		if d := time.Since(time.Unix(sec, 0)); d > inboundParseTimestampSkew || d < -inboundParseTimestampSkew {
+			return errors.New("timestamp out of range")
+		}
+		token := fields["token"]
+		if len(token) == 0 {
+			return errors.New("missing token")
+		}
+		if err := compareHMAC(signingKey, []byte(timestamp+token), signature); err != nil {
+			return err
+		}
+		// Only signed tokens are remembered, so others cannot use them up
+		if !useInboundToken(token, time.Unix(sec, 0).Add(inboundParseTimestampSkew)) {
+			return errors.New("token already used")
+		}
+		return nil
+	}
+
+	if signature := r.Header.Get("X-Inbound-Signature"); len(signature) > 0 {
+		return compareHMAC(signingKey, body, strings.TrimPrefix(signature, "sha256="))
+	}
+
+	return errors.New("missing signature")
+}
+
+// useInboundToken records token until expiresAt. It returns false when the
+// token is already recorded.
+func useInboundToken(token string, expiresAt time.Time) bool {
+	usedInboundTokensMu.Lock()
+	defer usedInboundTokensMu.Unlock()
+
+	now := time.Now()
+	for usedToken, usedExpiresAt := range usedInboundTokens {
+		if now.After(usedExpiresAt) {
+			delete(usedInboundTokens, usedToken)
+		}
+	}
+	if _, ok := usedInboundTokens[token]; ok {
+		return false
+	}
+	usedInboundTokens[token] = expiresAt
+	return true
+}
+
+func compareHMAC(key string, message []byte, signature string) error {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return errors.New("malformed signature")
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(message)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
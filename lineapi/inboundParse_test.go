@@ -0,0 +1,192 @@
+package lineapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSigningKey = "key-3ax6xnjp29jd6fds4gc373sgvjxteol0"
+
+func signInbound(key string, message string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyInboundSignature(t *testing.T) {
+	usedInboundTokensMu.Lock()
+	usedInboundTokens = make(map[string]time.Time)
+	usedInboundTokensMu.Unlock()
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-inboundParseTimestampSkew-time.Minute).Unix(), 10)
+	future := strconv.FormatInt(time.Now().Add(inboundParseTimestampSkew+time.Minute).Unix(), 10)
+	body := "email=From%3A+alice%40example.com"
+
+	tests := []struct {
+		name       string
+		signingKey string
+		fields     map[string]string
+		header     string
+		wantErr    bool
+	}{
+		{
+			name:       "valid token signature",
+			signingKey: testSigningKey,
+			fields:     map[string]string{"timestamp": now, "token": "token-1", "signature": signInbound(testSigningKey, now+"token-1")},
+		},
+		{
+			name:       "replayed token",
+			signingKey: testSigningKey,
+			fields:     map[string]string{"timestamp": now, "token": "token-1", "signature": signInbound(testSigningKey, now+"token-1")},
+			wantErr:    true,
+		},
+		{
+			name:       "wrong key",
+			signingKey: testSigningKey,
+			fields:     map[string]string{"timestamp": now, "token": "token-2", "signature": signInbound("other-key", now+"token-2")},
+			wantErr:    true,
+		},
+		{
+			name:       "token changed after signing",
+			signingKey: testSigningKey,
+			fields:     map[string]string{"timestamp": now, "token": "token-3", "signature": signInbound(testSigningKey, now+"token-2")},
+			wantErr:    true,
+		},
+		{
+			name:       "malformed signature",
+			signingKey: testSigningKey,
+			fields:     map[string]string{"timestamp": now, "token": "token-4", "signature": "not hex"},
+			wantErr:    true,
+		},
+		{
+			name:       "timestamp too old",
+			signingKey: testSigningKey,
+			fields:     map[string]string{"timestamp": old, "token": "token-5", "signature": signInbound(testSigningKey, old+"token-5")},
+			wantErr:    true,
+		},
+		{
+			name:       "timestamp in the future",
+			signingKey: testSigningKey,
+			fields:     map[string]string{"timestamp": future, "token": "token-6", "signature": signInbound(testSigningKey, future+"token-6")},
+			wantErr:    true,
+		},
+		{
+			name:       "invalid timestamp",
+			signingKey: testSigningKey,
+			fields:     map[string]string{"timestamp": "yesterday", "token": "token-7", "signature": signInbound(testSigningKey, "yesterdaytoken-7")},
+			wantErr:    true,
+		},
+		{
+			name:       "missing token",
+			signingKey: testSigningKey,
+			fields:     map[string]string{"timestamp": now, "signature": signInbound(testSigningKey, now)},
+			wantErr:    true,
+		},
+		{
+			name:       "valid body signature",
+			signingKey: testSigningKey,
+			header:     "sha256=" + signInbound(testSigningKey, body),
+		},
+		{
+			name:       "body signature of another body",
+			signingKey: testSigningKey,
+			header:     "sha256=" + signInbound(testSigningKey, body+"&to=mallory%40example.com"),
+			wantErr:    true,
+		},
+		{
+			name:       "missing signature",
+			signingKey: testSigningKey,
+			wantErr:    true,
+		},
+		{
+			name:    "signing key not configured",
+			fields:  map[string]string{"timestamp": now, "token": "token-8", "signature": signInbound("", now+"token-8")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/inbound", strings.NewReader(body))
+			if len(tt.header) > 0 {
+				r.Header.Set("X-Inbound-Signature", tt.header)
+			}
+			fields := tt.fields
+			if fields == nil {
+				fields = map[string]string{}
+			}
+			err := verifyInboundSignature(tt.signingKey, r, []byte(body), fields)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyInboundSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUseInboundTokenForgetsExpiredTokens(t *testing.T) {
+	if !useInboundToken("expired-token", time.Now().Add(-time.Second)) {
+		t.Fatal("first use is rejected")
+	}
+	// The expired entry is purged before the lookup
+	if !useInboundToken("expired-token", time.Now().Add(time.Minute)) {
+		t.Error("expired token is still remembered")
+	}
+	if useInboundToken("expired-token", time.Now().Add(time.Minute)) {
+		t.Error("token in range is accepted twice")
+	}
+}
+
+func TestReadInboundFields(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        map[string]string
+	}{
+		{
+			name:        "urlencoded",
+			contentType: "application/x-www-form-urlencoded",
+			body:        url.Values{"recipient": {"taro@example.jp"}, "token": {"t"}}.Encode(),
+			want:        map[string]string{"recipient": "taro@example.jp", "token": "t"},
+		},
+		{
+			name:        "JSON with nested envelope",
+			contentType: "application/json",
+			body:        `{"email":"raw","envelope":{"to":["taro@example.jp"]},"spam":null}`,
+			want:        map[string]string{"email": "raw", "envelope": `{"to":["taro@example.jp"]}`},
+		},
+		{
+			name:        "multipart",
+			contentType: "multipart/form-data; boundary=xYzZY",
+			body: "--xYzZY\r\nContent-Disposition: form-data; name=\"to\"\r\n\r\ntaro@example.jp\r\n" +
+				"--xYzZY\r\nContent-Disposition: form-data; name=\"subject\"\r\n\r\nHello\r\n--xYzZY--\r\n",
+			want: map[string]string{"to": "taro@example.jp", "subject": "Hello"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/inbound", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			got, err := readInboundFields(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			for key, value := range tt.want {
+				if got[key] != value {
+					t.Errorf("%s = %q, want %q", key, got[key], value)
+				}
+			}
+		})
+	}
+}
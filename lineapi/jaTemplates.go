@@ -0,0 +1,193 @@
+package lineapi
+
+// jaTemplates ..
+var jaTemplates = map[string]string{
+	// Buttons
+	"label_yes.txt":            "はい",
+	"label_no.txt":             "いいえ",
+	"label_read_body.txt":      "本文を見る",
+	"label_reply.txt":          "返信する",
+	"label_open.txt":           "開く",
+	"label_send.txt":           "送信",
+	"label_cancel.txt":         "中止",
+	"label_finish.txt":         "完了",
+	"label_command_add.txt":    "設定する",
+	"label_command_list.txt":   "一覧",
+	"label_command_remove.txt": "解除",
+	"label_command_mute.txt":   "ミュート",
+	"label_command_unmute.txt": "ミュート解除",
+	"label_command_help.txt":   "ヘルプ",
+	"label_remove_all.txt":     "すべて解除",
+	"label_snooze_1h.txt":      "1時間",
+	"label_snooze_today.txt":   "今日",
+	"label_snooze_forever.txt": "再開まで",
+
+	// Setup and revoke
+	"introduction.txt": `登録ありがとうございます！メールお知らせくんです。
+登録されたメールアドレスにメールが届くとお知らせします。`,
+	"registered_addresses.txt": `こんにちは！メールお知らせくんです。
+{{if .Addresses}}現在お知らせ設定されているメールアドレスは
+{{join .Addresses "\n"}}
+です{{else}}現在お知らせ設定されているメールアドレスはありません{{end}}{{if .Muted}}
+お知らせは一時停止中です{{end}}`,
+	"confirm_setup.txt":  `{{if .Addresses}}メールお知らせを再設定しますか？{{else}}メールお知らせを設定しますか？{{end}}`,
+	"confirm_revoke.txt": "メールお知らせを解除しますか？",
+	"revoked.txt":        "お知らせ設定を削除しました！",
+	"remove_choose.txt": `お知らせを解除するメールアドレスを選んでください{{if gt .More 0}}
+ほかの{{.More}}件は「{{.Command}} <メールアドレス>」で解除できます{{end}}`,
+	"confirm_remove.txt": "{{.Address}} のお知らせを解除しますか？",
+	// random_reply.txt has one reply per line
+	"random_reply.txt": `ごめんなさい！よく分かりませんでした！
+「メールお知らせくん」と呼んでいただければメールお知らせ設定が確認できます
+「お知らせ解除」と言っていただければメールお知らせを解除できます
+新しいメールはたぶんありません！
+「/help」でコマンドの一覧が見られます`,
+	"configure_already_started.txt": `すでに設定中です
+終了するには「.」を入力してください`,
+	"configure_started.txt": `メールアドレスを１件ずつ入力してください
+終了するには「.」を入力してください`,
+	"configure_finished.txt": `{{if .Sent}}以下の{{len .Sent}}個のメールアドレスに確認コードをお送りしました。メールを確認して確認コードを入力してください
+{{join .Sent "\n"}}
+{{end}}{{if .Failed}}以下のメールアドレスへの確認コードの送信に失敗しました。時間をおいて再度お試しください
+{{join .Failed "\n"}}
+{{end}}{{if not (or .Sent .Failed)}}メールアドレスが設定されませんでした{{end}}`,
+
+	// Address entry
+	"address_accepted.txt":           "{{.Address}} を受け付けました（{{.Count}}件目）",
+	"address_invalid.txt":            "{{.Address}} はメールアドレスとして正しくありません",
+	"address_duplicate.txt":          "{{.Address}} はすでに入力されています",
+	"address_already_registered.txt": "{{.Address}} はすでに登録されています",
+
+	// Verification
+	"verification_code_invalid.txt": "無効な確認コードです",
+	"verification_code_expired.txt": "確認コードの有効期限が切れました",
+	"address_verified.txt": `メールアドレスが確認されました
+{{.Address}}
+以下のメールアドレス宛にメール転送設定を行うとお知らせが来るようになります
+{{.ForwardingAddress}}`,
+	"verification_mail_subject.txt": "LINEBOT: メールお知らせくん登録確認",
+	"verification_mail.txt": `この度はメールお知らせくんのご利用ありがとうございます。
+LINEに戻って以下の確認コードを送信してください。
+確認コード：{{.Code}}
+`,
+	"verification_mail.html": `<p>この度はメールお知らせくんのご利用ありがとうございます。</p>
+<p>LINEに戻って以下の確認コードを送信してください。</p>
+<p>確認コード：<code>{{.Code}}</code></p>
+`,
+
+	// Notification
+	"push_notification.txt": `新着メールが{{len .Mails}}件あります
+{{range $i, $mail := .Mails}}{{if gt (len $.Mails) 1}}{{inc $i}}.
+{{end}}宛先: {{accountMark $mail.AddressColor $mail.AddressEmoji}}{{$mail.AccountName}}
+差出人: {{if $mail.MailFrom}}{{$mail.MailFromDisplayName}}{{else}}(不明){{end}}
+件名: {{$mail.MailSubject}}
+{{range $mail.Attachments}}添付: {{.Name}} ({{.FormatSize}})
+{{end}}{{end}}`,
+	"mail_no_subject.txt":       "(件名なし)",
+	"mail_action_text.txt":      "宛先: {{.Account}}\n差出人: {{.From}}",
+	"mail_body_header.txt":      "差出人: {{.From}}\n件名: {{.Subject}}",
+	"mail_body_empty.txt":       "(本文がありません)",
+	"mail_body_omitted.txt":     "(以下省略)",
+	"mail_body_unavailable.txt": "メールの保存期間が過ぎたか、メールが大きすぎるため本文を表示できません",
+
+	// Attachments
+	"attachment_expired.txt":         "メールの保存期間が過ぎたため添付ファイルを送信できません",
+	"attachment_not_found.txt":       "添付ファイルが見つかりませんでした",
+	"attachment_not_forwardable.txt": "この添付ファイルは送信できません",
+	"attachment_alt_text.txt":        "添付ファイル: {{.Name}}",
+
+	// Reply
+	"reply_expired.txt":     "メールの保存期間が過ぎたため返信できません",
+	"reply_unavailable.txt": "このメールには返信できません",
+	"reply_prompt.txt": `{{.To}} への返信内容を入力してください
+中止するには「{{.CancelWord}}」と入力してください`,
+	"reply_confirm.txt":  "以下の内容で返信しますか？",
+	"reply_nothing.txt":  "送信する返信がありません",
+	"reply_sent.txt":     "{{.To}} に返信しました",
+	"reply_failed.txt":   "{{.To}} への返信の送信に失敗しました",
+	"reply_canceled.txt": "返信を中止しました",
+
+	// Commands
+	"help_header.txt":     "コマンドの一覧です",
+	"help_help.txt":       "この一覧を表示します",
+	"help_status.txt":     "お知らせ設定を確認・再設定します",
+	"help_list.txt":       "登録されているメールアドレスを表示します",
+	"help_add.txt":        "メールアドレスを追加します",
+	"help_remove.txt":     "メールアドレスのお知らせを解除します。省略すると解除するメールアドレスを選べます",
+	"help_mute.txt":       "お知らせを一時停止します",
+	"help_unmute.txt":     "お知らせを再開します",
+	"help_snooze.txt":     "メールアドレスごとにお知らせを一時停止します",
+	"help_resume.txt":     "一時停止したお知らせを再開します。省略するとすべて再開します",
+	"help_label.txt":      "通知に表示するメールアドレスの名前を付けます。「-」で消します",
+	"help_emoji.txt":      "通知に表示するメールアドレスの絵文字を付けます。「-」で消します",
+	"help_color.txt":      "通知に表示するメールアドレスの色を付けます。「-」で消します",
+	"help_language.txt":   "表示する言語を変更します",
+	"help_cancel.txt":     "設定中や返信中の操作を中止します",
+	"unknown_command.txt": "「{{.Command}}」というコマンドはありません\n「/help」でコマンドの一覧が見られます",
+	"address_list.txt": `{{if .Addresses}}登録されているメールアドレス
+{{join .Addresses "\n"}}{{else}}登録されているメールアドレスはありません{{end}}{{if .Muted}}
+お知らせは一時停止中です{{end}}`,
+	"address_removed.txt": `{{if .Removed}}以下のメールアドレスのお知らせを解除しました
+{{join .Removed "\n"}}
+{{end}}{{if .NotFound}}以下のメールアドレスは登録されていません
+{{join .NotFound "\n"}}{{end}}`,
+	"address_not_registered.txt": "{{.Address}} は登録されていません",
+	"address_snoozed.txt":        "{{.Address}}（{{if .Until}}{{.Until}}まで{{else}}再開まで{{end}}一時停止中）",
+	"time_format.txt":            "1/2 15:04",
+	"snooze_choose_address.txt":  "お知らせを一時停止するメールアドレスを選んでください",
+	"snooze_choose_duration.txt": "{{.Address}} のお知らせをいつまで一時停止しますか？",
+	"snoozed.txt": `{{.Address}} のお知らせを{{if .Until}}{{.Until}}まで{{else}}再開するまで{{end}}一時停止しました
+「/resume」で再開できます`,
+	"resumed.txt": `{{if .Addresses}}お知らせを再開しました
+{{join .Addresses "\n"}}{{else}}一時停止中のメールアドレスはありません{{end}}`,
+	"address_label_changed.txt": "通知には次のように表示されます\n{{.Name}}",
+	"address_label_usage.txt": `「{{.Command}}」の形で送ってください
+メールアドレスが1件だけのときは省略できます。「{{.Clear}}」で消します{{if .IsColor}}
+使える色: {{join .Colors ", "}}{{end}}`,
+	"muted.txt":   "お知らせを一時停止しました\n再開するには「/unmute」と入力してください",
+	"unmuted.txt": "お知らせを再開しました",
+
+	// Language
+	"language_changed.txt": "言語を日本語に変更しました",
+	"language_usage.txt": `現在の言語: {{.Language}}
+「{{.Command}} <言語コード>」で変更できます
+対応している言語: {{join .Languages ", "}}`,
+
+	// Conversation
+	"configure_canceled.txt":   "メールアドレスの設定を中止しました",
+	"canceled.txt":             "中止しました",
+	"conversation_expired.txt": "この操作は時間切れか、すでに終了しています",
+	"nothing_to_cancel.txt":    "中止する操作はありません",
+
+	// Settings page
+	"liff_settings.html":           liffSettingsPage,
+	"liff_title.txt":               "メールお知らせくんの設定",
+	"liff_language.txt":            "言語",
+	"liff_mute_all.txt":            "すべてのお知らせを一時停止する",
+	"liff_addresses.txt":           "メールアドレス",
+	"liff_no_addresses.txt":        "登録されているメールアドレスはありません",
+	"liff_label.txt":               "名前",
+	"liff_emoji.txt":               "絵文字",
+	"liff_color.txt":               "色",
+	"liff_none.txt":                "なし",
+	"liff_snooze.txt":              "一時停止",
+	"liff_snooze_keep.txt":         "変更しない",
+	"liff_resume.txt":              "再開する",
+	"liff_paused.txt":              "再開まで一時停止中",
+	"liff_paused_until.txt":        "一時停止中:",
+	"liff_remove.txt":              "お知らせを解除する",
+	"liff_save.txt":                "保存",
+	"liff_saved.txt":               "保存しました",
+	"liff_add.txt":                 "メールアドレスを追加",
+	"liff_quiet_hours.txt":         "おやすみ時間",
+	"liff_quiet_hours_enabled.txt": "おやすみ時間のお知らせを終わってからまとめて送る",
+	"liff_quiet_hours_start.txt":   "開始",
+	"liff_quiet_hours_end.txt":     "終了",
+	"liff_filters.txt":             "フィルタ",
+	"liff_filters_help.txt":        "指定した文字を含むメールはお知らせしません",
+	"liff_filter_add.txt":          "フィルタを追加",
+	"liff_filter_remove.txt":       "削除",
+	"liff_filter_from.txt":         "差出人",
+	"liff_filter_to.txt":           "宛先",
+	"liff_filter_subject.txt":      "件名",
+}
@@ -0,0 +1,229 @@
+package lineapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+)
+
+const (
+	// fallbackLanguage has every template and keyword
+	fallbackLanguage = "ja"
+	profileAPIURL    = "https://api.line.me/v2/bot/profile/"
+)
+
+// keywords are localized command words by language and command name.
+// Words of every language are accepted regardless of the user's language.
+var keywords = map[string]map[string][]string{
+	"ja": {
+		"help":     {"ヘルプ"},
+		"status":   {"メールお知らせくん", "メールお知らせ", "メールおしらせ"},
+		"list":     {"一覧"},
+		"add":      {"追加"},
+		"remove":   {"お知らせ解除", "削除"},
+		"mute":     {"ミュート"},
+		"unmute":   {"ミュート解除"},
+		"snooze":   {"スヌーズ"},
+		"resume":   {"再開"},
+		"label":    {"ラベル"},
+		"emoji":    {"絵文字"},
+		"color":    {"色"},
+		"language": {"言語"},
+		"cancel":   {"キャンセル"},
+	},
+	"en": {
+		"help":     {"help"},
+		"status":   {"mail notice", "status"},
+		"list":     {"list"},
+		"add":      {"add"},
+		"remove":   {"stop notice", "remove"},
+		"mute":     {"mute"},
+		"unmute":   {"unmute"},
+		"snooze":   {"snooze"},
+		"resume":   {"resume"},
+		"label":    {"label"},
+		"emoji":    {"emoji"},
+		"color":    {"color", "colour"},
+		"language": {"language"},
+		"cancel":   {"cancel"},
+	},
+}
+
+// defaultLanguage returns DEFAULT_LANGUAGE, or Japanese
+func defaultLanguage() string {
+	configVars := helper.ConfigVars()
+	if language := normalizeLanguage(configVars.DefaultLanguage); len(language) > 0 {
+		return language
+	}
+	return fallbackLanguage
+}
+
+var (
+	supportedLanguagesCache   []string
+	supportedLanguagesCacheMu sync.Mutex
+)
+
+// supportedLanguages lists built-in languages and those added in TEMPLATE_DIR/<language>/.
+// Like templates, the list is read once and cached until the process restarts.
+func supportedLanguages() []string {
+	supportedLanguagesCacheMu.Lock()
+	defer supportedLanguagesCacheMu.Unlock()
+	if supportedLanguagesCache == nil {
+		supportedLanguagesCache = readSupportedLanguages()
+	}
+	return supportedLanguagesCache
+}
+
+// readSupportedLanguages looks up the languages of supportedLanguages
+func readSupportedLanguages() []string {
+	configVars := helper.ConfigVars()
+	found := make(map[string]bool)
+	for language := range defaultTemplates {
+		found[language] = true
+	}
+	if len(configVars.TemplateDir) > 0 {
+		if files, err := ioutil.ReadDir(configVars.TemplateDir); err == nil {
+			for _, file := range files {
+				if file.IsDir() {
+					found[strings.ToLower(file.Name())] = true
+				}
+			}
+		}
+	}
+
+	languages := make([]string, 0, len(found))
+	for language := range found {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// normalizeLanguage maps a BCP 47 tag such as "en-US" to a supported
+// language, or returns "" when it is not supported
+func normalizeLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if len(tag) == 0 || strings.ContainsAny(tag, `/\.`) {
+		return ""
+	}
+	candidates := []string{tag}
+	if i := strings.IndexAny(tag, "-_"); i > 0 {
+		candidates = append(candidates, tag[:i])
+	}
+	languages := supportedLanguages()
+	for _, candidate := range candidates {
+		for _, language := range languages {
+			if candidate == language {
+				return language
+			}
+		}
+	}
+	return ""
+}
+
+// userLanguage returns the language chosen by lineID
+func userLanguage(lineID string) string {
+	configVars := helper.ConfigVars()
+	return lineUserLanguage(mongodb.ReadLineUser(lineID, configVars.MongodbURI))
+}
+
+// lineUserLanguage returns the language chosen by an already loaded lineUser
+func lineUserLanguage(lineUser mongodb.LineUser) string {
+	if language := normalizeLanguage(lineUser.Language); len(language) > 0 {
+		return language
+	}
+	return defaultLanguage()
+}
+
+// keyword returns the first command word of key in language
+func keyword(language, key string) string {
+	if words := keywords[language][key]; len(words) > 0 {
+		return words[0]
+	}
+	return keywords[fallbackLanguage][key][0]
+}
+
+// isKeyword reports whether text is exactly a command word of key in any language
+func isKeyword(text, key string) bool {
+	text = strings.TrimSpace(text)
+	for _, words := range keywords {
+		for _, word := range words[key] {
+			if strings.EqualFold(text, word) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fetchProfileLanguage reads the language of a user from the LINE profile API.
+// The SDK's profile response does not carry the language field.
+func fetchProfileLanguage(userID string) string {
+	configVars := helper.ConfigVars()
+
+	req, err := http.NewRequest(http.MethodGet, profileAPIURL+url.PathEscape(userID), nil)
+	if err != nil {
+		log.Print(err)
+		return ""
+	}
+	req.Header.Set("Authorization", "Bearer "+configVars.LineAPI.AccessToken)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		log.Print(err)
+		return ""
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		log.Print("profile API: ", res.Status)
+		return ""
+	}
+
+	var profile struct {
+		Language string `json:"language"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&profile); err != nil {
+		log.Print(err)
+		return ""
+	}
+	return profile.Language
+}
+
+// DetectUserLanguage stores the language of a new friend from the LINE profile
+func DetectUserLanguage(lineID string) string {
+	configVars := helper.ConfigVars()
+	language := normalizeLanguage(fetchProfileLanguage(lineID))
+	if len(language) == 0 {
+		return defaultLanguage()
+	}
+	mongodb.UpdateLineUserLanguage(lineID, language, configVars.MongodbURI)
+	return language
+}
+
+// changeLanguage switches the language with "/language <code>", or shows
+// the current and supported languages
+func changeLanguage(ctx commandContext) {
+	configVars := helper.ConfigVars()
+
+	if len(ctx.Args) == 1 && len(normalizeLanguage(ctx.Args[0])) > 0 {
+		language := normalizeLanguage(ctx.Args[0])
+		mongodb.UpdateLineUserLanguage(ctx.LineID, language, configVars.MongodbURI)
+		replyText(ctx, renderTemplate(language, "language_changed.txt", templateData{"Language": language}))
+		return
+	}
+	replyText(ctx, renderTemplate(ctx.Language, "language_usage.txt", templateData{
+		"Language":  ctx.Language,
+		"Languages": supportedLanguages(),
+		"Command":   commandPrefix + "language",
+	}))
+}
@@ -0,0 +1,350 @@
+package lineapi
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+)
+
+const (
+	// LIFFPath serves the settings page opened in LIFF
+	LIFFPath = "/liff/"
+	// LIFFAPIPath serves the JSON API of the settings page
+	LIFFAPIPath = "/liff/api/"
+
+	idTokenVerifyURL = "https://api.line.me/oauth2/v2.1/verify"
+	// liffAPIMaxBytes bounds request bodies of the settings API
+	liffAPIMaxBytes = 64 << 10
+	// snoozeResume resumes a snoozed address in the settings API
+	snoozeResume = "resume"
+)
+
+// liffSettings are the settings of a LINE user shown on the settings page.
+// Updates replace QuietHours and Filters; null removes them.
+type liffSettings struct {
+	Language     string          `json:"language"`
+	Languages    []string        `json:"languages,omitempty"`
+	Colors       []string        `json:"colors,omitempty"`
+	FilterFields []string        `json:"filterFields,omitempty"`
+	Muted        bool            `json:"muted"`
+	QuietHours   *liffQuietHours `json:"quietHours"`
+	Filters      []liffFilter    `json:"filters"`
+	Addresses    []liffAddress   `json:"addresses"`
+}
+
+// liffQuietHours are the quiet hours on the settings page
+type liffQuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// liffFilter is a notification filter on the settings page
+type liffFilter struct {
+	Field   string `json:"field"`
+	Pattern string `json:"pattern"`
+}
+
+// liffAddress is a registered address on the settings page. Snooze and
+// Remove are only read in updates.
+type liffAddress struct {
+	Address    string     `json:"address"`
+	Label      string     `json:"label"`
+	Emoji      string     `json:"emoji"`
+	Color      string     `json:"color"`
+	Muted      bool       `json:"muted"`
+	MutedUntil *time.Time `json:"mutedUntil,omitempty"`
+	Snooze     string     `json:"snooze,omitempty"`
+	Remove     bool       `json:"remove,omitempty"`
+}
+
+// liffError is the body of an error response
+type liffError struct {
+	Error string `json:"error"`
+}
+
+// verifyIDToken verifies a LIFF ID token with the LINE Login channel of
+// LINE_LOGIN_CHANNEL_ID and returns the user ID. The channel must belong to
+// the same provider as the bot for the user IDs to match.
+func verifyIDToken(idToken string) (string, error) {
+	configVars := helper.ConfigVars()
+	if len(idToken) == 0 {
+		return "", errors.New("no ID token")
+	}
+
+	form := url.Values{
+		"id_token":  {idToken},
+		"client_id": {configVars.LineAPI.LoginChannelID},
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.PostForm(idTokenVerifyURL, form)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", errors.New("ID token verification: " + res.Status)
+	}
+
+	var claims struct {
+		Subject  string `json:"sub"`
+		Audience string `json:"aud"`
+		Expires  int64  `json:"exp"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&claims); err != nil {
+		return "", err
+	}
+	if claims.Audience != configVars.LineAPI.LoginChannelID || len(claims.Subject) == 0 {
+		return "", errors.New("ID token verification: unexpected claims")
+	}
+	if time.Now().Unix() > claims.Expires {
+		return "", errors.New("ID token verification: expired")
+	}
+	return claims.Subject, nil
+}
+
+// writeJSON writes v as the JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Print(err)
+	}
+}
+
+// readLIFFSettings returns the settings of lineID
+func readLIFFSettings(lineID string) liffSettings {
+	configVars := helper.ConfigVars()
+	lineUser := mongodb.ReadLineUser(lineID, configVars.MongodbURI)
+
+	settings := liffSettings{
+		Language:     lineUserLanguage(lineUser),
+		Languages:    supportedLanguages(),
+		FilterFields: filterFields,
+		Muted:        lineUser.Muted,
+		Filters:      []liffFilter{},
+		Addresses:    []liffAddress{},
+	}
+	for _, color := range labelColors {
+		settings.Colors = append(settings.Colors, color.Name)
+	}
+	if lineUser.QuietHours != nil {
+		settings.QuietHours = &liffQuietHours{Start: lineUser.QuietHours.Start, End: lineUser.QuietHours.End}
+	}
+	for _, filter := range lineUser.Filters {
+		settings.Filters = append(settings.Filters, liffFilter{Field: filter.Field, Pattern: filter.Pattern})
+	}
+	now := time.Now()
+	for _, registeredAddress := range lineUser.RegisteredAddresses {
+		address := liffAddress{
+			Address: registeredAddress.Address,
+			Label:   registeredAddress.Label,
+			Emoji:   registeredAddress.Emoji,
+			Color:   registeredAddress.Color,
+			Muted:   registeredAddress.Muted,
+		}
+		if !registeredAddress.Muted && registeredAddress.IsMuted(now) {
+			mutedUntil := registeredAddress.MutedUntil
+			address.MutedUntil = &mutedUntil
+		}
+		settings.Addresses = append(settings.Addresses, address)
+	}
+	return settings
+}
+
+// validateLIFFSettings checks an update before anything is written
+func validateLIFFSettings(lineUser mongodb.LineUser, update liffSettings) error {
+	if len(update.Language) > 0 && len(normalizeLanguage(update.Language)) == 0 {
+		return errors.New("unsupported language: " + update.Language)
+	}
+	if update.QuietHours != nil && !validateQuietHours(update.QuietHours.quietHours()) {
+		return errors.New("invalid quiet hours: " + update.QuietHours.Start + "-" + update.QuietHours.End)
+	}
+	if len(update.Filters) > maxFilters {
+		return errors.New("too many filters")
+	}
+	for _, filter := range update.Filters {
+		if !isFilterField(filter.Field) {
+			return errors.New("unknown filter field: " + filter.Field)
+		}
+		pattern := strings.TrimSpace(filter.Pattern)
+		if len(pattern) == 0 || utf8.RuneCountInString(pattern) > maxFilterPatternLength {
+			return errors.New("invalid filter pattern: " + filter.Pattern)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, address := range update.Addresses {
+		if lineUser.FindRegisteredAddress(address.Address) == nil {
+			return errors.New("not registered: " + address.Address)
+		}
+		if seen[address.Address] {
+			return errors.New("duplicate address: " + address.Address)
+		}
+		seen[address.Address] = true
+		if len(address.Color) > 0 && findLabelColor(address.Color) == nil {
+			return errors.New("unknown color: " + address.Color)
+		}
+		if _, ok := snoozeUntil(address.Snooze, time.Now()); !ok && len(address.Snooze) > 0 && address.Snooze != snoozeResume {
+			return errors.New("unknown snooze: " + address.Snooze)
+		}
+	}
+	return nil
+}
+
+// quietHours converts the quiet hours of the settings page to the stored form
+func (q liffQuietHours) quietHours() mongodb.QuietHours {
+	return mongodb.QuietHours{Start: strings.TrimSpace(q.Start), End: strings.TrimSpace(q.End)}
+}
+
+// updateLIFFSettings writes an update validated by validateLIFFSettings
+func updateLIFFSettings(lineUser mongodb.LineUser, update liffSettings) {
+	configVars := helper.ConfigVars()
+	lineID := lineUser.LineID
+
+	if len(update.Language) > 0 {
+		mongodb.UpdateLineUserLanguage(lineID, normalizeLanguage(update.Language), configVars.MongodbURI)
+	}
+	if update.Muted != lineUser.Muted {
+		mongodb.UpdateLineUserMuted(lineID, update.Muted, configVars.MongodbURI)
+	}
+	var quietHours *mongodb.QuietHours
+	if update.QuietHours != nil {
+		q := update.QuietHours.quietHours()
+		quietHours = &q
+	}
+	if !reflect.DeepEqual(quietHours, lineUser.QuietHours) {
+		mongodb.UpdateLineUserQuietHours(lineID, quietHours, configVars.MongodbURI)
+	}
+	var filters []mongodb.NotificationFilter
+	for _, filter := range update.Filters {
+		filters = append(filters, mongodb.NotificationFilter{Field: filter.Field, Pattern: strings.TrimSpace(filter.Pattern)})
+	}
+	if !reflect.DeepEqual(filters, lineUser.Filters) {
+		mongodb.UpdateLineUserFilters(lineID, filters, configVars.MongodbURI)
+	}
+	for _, address := range update.Addresses {
+		if address.Remove {
+			mongodb.DeleteRegisteredAddress(lineID, address.Address, configVars.MongodbURI)
+			continue
+		}
+
+		color := ""
+		if labelColor := findLabelColor(address.Color); labelColor != nil {
+			color = labelColor.Name
+		}
+		label := truncateText(strings.TrimSpace(address.Label), maxLabelLength)
+		emoji := truncateText(strings.TrimSpace(address.Emoji), maxEmojiLength)
+		current := lineUser.FindRegisteredAddress(address.Address)
+		if current.Label != label || current.Emoji != emoji || current.Color != color {
+			mongodb.UpdateRegisteredAddressLabel(lineID, address.Address, label, emoji, color, configVars.MongodbURI)
+		}
+
+		switch address.Snooze {
+		case "":
+		case snoozeResume:
+			mongodb.UpdateRegisteredAddressMute(lineID, address.Address, false, time.Time{}, configVars.MongodbURI)
+		default:
+			until, _ := snoozeUntil(address.Snooze, time.Now())
+			mongodb.UpdateRegisteredAddressMute(lineID, address.Address, until.IsZero(), until, configVars.MongodbURI)
+		}
+	}
+}
+
+// LIFFAPIHandler serves the settings of the LINE user of the ID token given as
+//
+//	Authorization: Bearer <ID token>
+//
+// GET settings reads them, PUT settings updates them and POST addresses
+// sends a verification code to a new address.
+func LIFFAPIHandler(w http.ResponseWriter, r *http.Request) {
+	configVars := helper.ConfigVars()
+
+	lineID, err := verifyIDToken(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if err != nil {
+		log.Print("LIFFAPI: ", err)
+		writeJSON(w, http.StatusUnauthorized, liffError{"unauthorized"})
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, liffAPIMaxBytes)
+
+	switch strings.TrimPrefix(r.URL.Path, LIFFAPIPath) {
+	case "settings":
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, readLIFFSettings(lineID))
+		case http.MethodPut:
+			var update liffSettings
+			if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+				writeJSON(w, http.StatusBadRequest, liffError{err.Error()})
+				return
+			}
+			lineUser := mongodb.ReadLineUser(lineID, configVars.MongodbURI)
+			lineUser.LineID = lineID
+			if err := validateLIFFSettings(lineUser, update); err != nil {
+				writeJSON(w, http.StatusBadRequest, liffError{err.Error()})
+				return
+			}
+			updateLIFFSettings(lineUser, update)
+			writeJSON(w, http.StatusOK, readLIFFSettings(lineID))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	case "addresses":
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var request struct {
+			Address string `json:"address"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeJSON(w, http.StatusBadRequest, liffError{err.Error()})
+			return
+		}
+		language := userLanguage(lineID)
+		address, rejection := validateAddress(lineID, request.Address, nil)
+		if len(rejection) > 0 {
+			writeJSON(w, http.StatusBadRequest, liffError{renderTemplate(language, rejection, templateData{"Address": address})})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"message": sendVerificationMails(lineID, language, []string{address})})
+	default:
+		writeJSON(w, http.StatusNotFound, liffError{"not found"})
+	}
+}
+
+// LIFFHandler serves the settings page. It signs in with LIFF and calls
+// LIFFAPIHandler with the ID token.
+func LIFFHandler(w http.ResponseWriter, r *http.Request) {
+	configVars := helper.ConfigVars()
+	if r.URL.Path != LIFFPath {
+		http.NotFound(w, r)
+		return
+	}
+
+	// The user is unknown until signed in, so the browser language is used
+	language := defaultLanguage()
+	for _, tag := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		if l := normalizeLanguage(strings.SplitN(tag, ";", 2)[0]); len(l) > 0 {
+			language = l
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(renderTemplate(language, "liff_settings.html", templateData{
+		"Language": language,
+		"LIFFID":   configVars.LineAPI.LIFFID,
+		"APIPath":  LIFFAPIPath,
+		"Labels":   liffLabels(language),
+	})))
+}
@@ -0,0 +1,212 @@
+package lineapi
+
+// liffLabelKeys are the templates of the texts on the settings page
+var liffLabelKeys = []string{
+	"liff_title", "liff_language", "liff_mute_all", "liff_addresses", "liff_no_addresses",
+	"liff_label", "liff_emoji", "liff_color", "liff_none", "liff_snooze", "liff_snooze_keep",
+	"liff_resume", "liff_paused", "liff_paused_until", "liff_remove", "liff_save", "liff_saved",
+	"liff_add", "label_snooze_1h", "label_snooze_today", "label_snooze_forever",
+	"liff_quiet_hours", "liff_quiet_hours_enabled", "liff_quiet_hours_start", "liff_quiet_hours_end",
+	"liff_filters", "liff_filters_help", "liff_filter_add", "liff_filter_remove",
+	"liff_filter_from", "liff_filter_to", "liff_filter_subject",
+}
+
+// liffLabels renders the texts on the settings page in language
+func liffLabels(language string) map[string]string {
+	labels := make(map[string]string)
+	for _, key := range liffLabelKeys {
+		labels[key] = renderTemplate(language, key+".txt", nil)
+	}
+	return labels
+}
+
+// liffSettingsPage is the settings page. Its texts are in Labels, so one
+// page serves every language.
+const liffSettingsPage = `<!DOCTYPE html>
+<html lang="{{.Language}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Labels.liff_title}}</title>
+<style>
+body { font-family: sans-serif; margin: 0; padding: 16px; color: #222; }
+fieldset { border: 1px solid #ddd; border-radius: 8px; margin: 0 0 12px; }
+label { display: block; margin: 6px 0; }
+input[type=text], input[type=email], input[type=time], select { width: 100%; box-sizing: border-box; padding: 6px; }
+button { padding: 8px 16px; margin: 4px 0; }
+#message { min-height: 1.5em; color: #06c755; }
+#message.error { color: #d00; }
+</style>
+<script src="https://static.line-scdn.net/liff/edge/2/sdk.js"></script>
+</head>
+<body>
+<h1>{{.Labels.liff_title}}</h1>
+<p id="message"></p>
+<form id="settings" hidden>
+  <label>{{.Labels.liff_language}} <select id="language"></select></label>
+  <label><input type="checkbox" id="muted"> {{.Labels.liff_mute_all}}</label>
+  <h2>{{.Labels.liff_quiet_hours}}</h2>
+  <label><input type="checkbox" id="quietHoursEnabled"> {{.Labels.liff_quiet_hours_enabled}}</label>
+  <label>{{.Labels.liff_quiet_hours_start}} <input type="time" id="quietHoursStart"></label>
+  <label>{{.Labels.liff_quiet_hours_end}} <input type="time" id="quietHoursEnd"></label>
+  <h2>{{.Labels.liff_filters}}</h2>
+  <p>{{.Labels.liff_filters_help}}</p>
+  <div id="filters"></div>
+  <button type="button" id="addFilter">{{.Labels.liff_filter_add}}</button>
+  <h2>{{.Labels.liff_addresses}}</h2>
+  <div id="addresses"></div>
+  <button type="submit">{{.Labels.liff_save}}</button>
+</form>
+<form id="add" hidden>
+  <label>{{.Labels.liff_add}} <input type="email" id="newAddress" required></label>
+  <button type="submit">{{.Labels.liff_add}}</button>
+</form>
+<script>
+const liffID = {{.LIFFID}};
+const apiPath = {{.APIPath}};
+const labels = {{.Labels}};
+let filterFields = [];
+const snoozes = [["", labels.liff_snooze_keep], ["1h", labels.label_snooze_1h], ["today", labels.label_snooze_today], ["forever", labels.label_snooze_forever], ["resume", labels.liff_resume]];
+
+function showMessage(text, isError) {
+  const message = document.getElementById("message");
+  message.textContent = text;
+  message.className = isError ? "error" : "";
+}
+
+function api(method, path, body) {
+  return fetch(apiPath + path, {
+    method: method,
+    headers: {"Authorization": "Bearer " + liff.getIDToken(), "Content-Type": "application/json"},
+    body: body ? JSON.stringify(body) : undefined,
+  }).then(res => res.json().then(json => {
+    if (!res.ok) {
+      throw new Error(json.error || res.statusText);
+    }
+    return json;
+  }));
+}
+
+function element(tag, attributes, children) {
+  const e = document.createElement(tag);
+  Object.keys(attributes || {}).forEach(key => { e[key] = attributes[key]; });
+  (children || []).forEach(child => e.append(child));
+  return e;
+}
+
+function options(select, values, selected) {
+  values.forEach(([value, text]) => select.append(element("option", {value: value, textContent: text, selected: value === selected})));
+  return select;
+}
+
+function filterRow(filter) {
+  const fields = filterFields.map(f => [f, labels["liff_filter_" + f] || f]);
+  const row = element("div", {className: "filter"}, [
+    options(element("select", {name: "field"}), fields, filter.field),
+    element("input", {type: "text", name: "pattern", value: filter.pattern, maxLength: 100, required: true}),
+    element("button", {type: "button", textContent: labels.liff_filter_remove}),
+  ]);
+  row.querySelector("button").addEventListener("click", () => row.remove());
+  return row;
+}
+
+function render(settings) {
+  const language = document.getElementById("language");
+  language.replaceChildren();
+  options(language, settings.languages.map(l => [l, l]), settings.language);
+  document.getElementById("muted").checked = settings.muted;
+
+  const quietHours = settings.quietHours || {start: "22:00", end: "07:00"};
+  document.getElementById("quietHoursEnabled").checked = !!settings.quietHours;
+  document.getElementById("quietHoursStart").value = quietHours.start;
+  document.getElementById("quietHoursEnd").value = quietHours.end;
+
+  filterFields = settings.filterFields;
+  const filters = document.getElementById("filters");
+  filters.replaceChildren();
+  settings.filters.forEach(filter => filters.append(filterRow(filter)));
+
+  const addresses = document.getElementById("addresses");
+  addresses.replaceChildren();
+  if (settings.addresses.length === 0) {
+    addresses.append(element("p", {textContent: labels.liff_no_addresses}));
+  }
+  const colors = [["", labels.liff_none]].concat(settings.colors.map(c => [c, c]));
+  settings.addresses.forEach(address => {
+    let state = "";
+    if (address.muted) {
+      state = labels.liff_paused;
+    } else if (address.mutedUntil) {
+      state = labels.liff_paused_until + " " + new Date(address.mutedUntil).toLocaleString();
+    }
+    const fieldset = element("fieldset", {}, [
+      element("legend", {textContent: address.address}),
+      element("p", {textContent: state}),
+      element("label", {textContent: labels.liff_label}, [element("input", {type: "text", name: "label", value: address.label, maxLength: 20})]),
+      element("label", {textContent: labels.liff_emoji}, [element("input", {type: "text", name: "emoji", value: address.emoji, maxLength: 8})]),
+      element("label", {textContent: labels.liff_color}, [options(element("select", {name: "color"}), colors, address.color)]),
+      element("label", {textContent: labels.liff_snooze}, [options(element("select", {name: "snooze"}), snoozes, "")]),
+      element("label", {}, [element("input", {type: "checkbox", name: "remove"}), " " + labels.liff_remove]),
+    ]);
+    fieldset.dataset.address = address.address;
+    addresses.append(fieldset);
+  });
+  document.getElementById("settings").hidden = false;
+  document.getElementById("add").hidden = false;
+}
+
+function collect() {
+  const addresses = Array.from(document.querySelectorAll("#addresses fieldset")).map(fieldset => ({
+    address: fieldset.dataset.address,
+    label: fieldset.querySelector("[name=label]").value,
+    emoji: fieldset.querySelector("[name=emoji]").value,
+    color: fieldset.querySelector("[name=color]").value,
+    snooze: fieldset.querySelector("[name=snooze]").value,
+    remove: fieldset.querySelector("[name=remove]").checked,
+  }));
+  const filters = Array.from(document.querySelectorAll("#filters .filter")).map(row => ({
+    field: row.querySelector("[name=field]").value,
+    pattern: row.querySelector("[name=pattern]").value,
+  }));
+  let quietHours = null;
+  if (document.getElementById("quietHoursEnabled").checked) {
+    quietHours = {start: document.getElementById("quietHoursStart").value, end: document.getElementById("quietHoursEnd").value};
+  }
+  return {
+    language: document.getElementById("language").value,
+    muted: document.getElementById("muted").checked,
+    quietHours: quietHours,
+    filters: filters,
+    addresses: addresses,
+  };
+}
+
+document.getElementById("addFilter").addEventListener("click", () => {
+  document.getElementById("filters").append(filterRow({field: filterFields[0], pattern: ""}));
+});
+
+document.getElementById("settings").addEventListener("submit", event => {
+  event.preventDefault();
+  api("PUT", "settings", collect())
+    .then(settings => { render(settings); showMessage(labels.liff_saved, false); })
+    .catch(err => showMessage(err.message, true));
+});
+
+document.getElementById("add").addEventListener("submit", event => {
+  event.preventDefault();
+  api("POST", "addresses", {address: document.getElementById("newAddress").value})
+    .then(res => { document.getElementById("newAddress").value = ""; showMessage(res.message, false); })
+    .catch(err => showMessage(err.message, true));
+});
+
+liff.init({liffId: liffID}).then(() => {
+  if (!liff.isLoggedIn()) {
+    liff.login();
+    return;
+  }
+  return api("GET", "settings").then(render);
+}).catch(err => showMessage(err.message, true));
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,45 @@
+package lineapi
+
+import (
+	"testing"
+
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+)
+
+func TestValidateLIFFSettings(t *testing.T) {
+	lineUser := mongodb.LineUser{
+		LineID: "U1",
+		RegisteredAddresses: []mongodb.RegisteredAddress{
+			{Address: "a@example.com"},
+			{Address: "b@example.com"},
+		},
+	}
+	tests := []struct {
+		name    string
+		update  liffSettings
+		wantErr bool
+	}{
+		{name: "empty", update: liffSettings{}},
+		{name: "every address once", update: liffSettings{Addresses: []liffAddress{{Address: "a@example.com", Label: "Work"}, {Address: "b@example.com", Remove: true}}}},
+		{name: "duplicate address", update: liffSettings{Addresses: []liffAddress{{Address: "a@example.com", Label: "Work"}, {Address: "a@example.com", Remove: true}}}, wantErr: true},
+		{name: "not registered", update: liffSettings{Addresses: []liffAddress{{Address: "c@example.com"}}}, wantErr: true},
+		{name: "unknown color", update: liffSettings{Addresses: []liffAddress{{Address: "a@example.com", Color: "ultraviolet"}}}, wantErr: true},
+		{name: "snooze", update: liffSettings{Addresses: []liffAddress{{Address: "a@example.com", Snooze: snoozeOneHour}, {Address: "b@example.com", Snooze: snoozeResume}}}},
+		{name: "unknown snooze", update: liffSettings{Addresses: []liffAddress{{Address: "a@example.com", Snooze: "1y"}}}, wantErr: true},
+		{name: "unsupported language", update: liffSettings{Language: "xx"}, wantErr: true},
+		{name: "quiet hours", update: liffSettings{QuietHours: &liffQuietHours{Start: "22:00", End: "07:00"}}},
+		{name: "quiet hours without end", update: liffSettings{QuietHours: &liffQuietHours{Start: "22:00"}}, wantErr: true},
+		{name: "empty quiet hours", update: liffSettings{QuietHours: &liffQuietHours{Start: "07:00", End: "07:00"}}, wantErr: true},
+		{name: "filters", update: liffSettings{Filters: []liffFilter{{Field: "from", Pattern: "newsletter"}, {Field: "subject", Pattern: "[ad]"}}}},
+		{name: "unknown filter field", update: liffSettings{Filters: []liffFilter{{Field: "body", Pattern: "sale"}}}, wantErr: true},
+		{name: "empty filter pattern", update: liffSettings{Filters: []liffFilter{{Field: "from", Pattern: " "}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLIFFSettings(lineUser, tt.update)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
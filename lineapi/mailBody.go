@@ -0,0 +1,111 @@
+package lineapi
+
+import (
+	"bytes"
+	"log"
+	"strings"
+
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+	"github.com/mshrtsr/mail-notice-linebot/mailmanager"
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+const (
+	// maxTextLength is the LINE limit of a text message in UTF-16 code units
+	maxTextLength = 5000
+	// maxReplyMessages is the number of messages LINE accepts in one reply
+	maxReplyMessages = 5
+)
+
+// readPostbackData ..
+func readPostbackData(mailID string) string {
+	return "read=" + mailID
+}
+
+// SendMailBody replies with the text body of an archived mail
+func SendMailBody(bot *linebot.Client, replyToken string, lineID string, mailID string) {
+	configVars := helper.ConfigVars()
+	language := userLanguage(lineID)
+
+	var messages []linebot.SendingMessage
+	mailArchive := mongodb.ReadMailArchive(mailID, configVars.MongodbURI)
+	if !mailArchiveReadableBy(mailArchive, lineID) || len(mailArchive.Raw) == 0 {
+		messages = append(messages, linebot.NewTextMessage(renderTemplate(language, "mail_body_unavailable.txt", nil)))
+	} else {
+		var textContents string
+		if msg, err := mailmanager.ParseMessage(bytes.NewReader(mailArchive.Raw)); err == nil {
+			textContents += renderTemplate(language, "mail_body_header.txt", templateData{
+				"From":    mailmanager.JoinDisplayNames(mailmanager.EnvelopeOriginator(msg.Envelope)),
+				"Subject": msg.Envelope.Subject,
+			}) + "\n\n"
+		}
+		body, err := mailmanager.ExtractText(mailArchive.Raw)
+		if err != nil {
+			log.Print(err)
+			body = renderTemplate(language, "mail_body_empty.txt", nil)
+		}
+		textContents += body
+
+		chunks := splitText(textContents, maxTextLength)
+		if len(chunks) > maxReplyMessages {
+			chunks = chunks[:maxReplyMessages]
+			chunks[maxReplyMessages-1] = truncateText(chunks[maxReplyMessages-1], maxTextLength/2) + "\n" + renderTemplate(language, "mail_body_omitted.txt", nil)
+		}
+		for _, chunk := range chunks {
+			messages = append(messages, linebot.NewTextMessage(chunk))
+		}
+	}
+
+	// Send messages
+	if _, err := bot.ReplyMessage(replyToken, messages...).Do(); err != nil {
+		log.Print(err)
+	}
+}
+
+// splitText splits text into chunks of at most max UTF-16 code units,
+// breaking at newlines where possible
+func splitText(text string, max int) []string {
+	var chunks []string
+	var chunk []rune
+	length := 0
+	lastNewline := -1
+
+	for _, r := range text {
+		units := 1
+		if r >= 0x10000 {
+			units = 2
+		}
+		if length+units > max {
+			cut := len(chunk)
+			if lastNewline > 0 {
+				cut = lastNewline + 1
+			}
+			chunks = append(chunks, strings.TrimRight(string(chunk[:cut]), "\n"))
+			chunk = append([]rune{}, chunk[cut:]...)
+			length = utf16Length(chunk)
+			lastNewline = -1
+		}
+		if r == '\n' {
+			lastNewline = len(chunk)
+		}
+		chunk = append(chunk, r)
+		length += units
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, string(chunk))
+	}
+	return chunks
+}
+
+func utf16Length(runes []rune) int {
+	length := 0
+	for _, r := range runes {
+		length++
+		if r >= 0x10000 {
+			length++
+		}
+	}
+	return length
+}
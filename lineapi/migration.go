@@ -0,0 +1,35 @@
+package lineapi
+
+import (
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+	"github.com/mshrtsr/mail-notice-linebot/mailmanager"
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+)
+
+// MigrateRegisteredAddresses converts legacy registered addresses, storing
+// them normalized like addresses verified now
+func MigrateRegisteredAddresses() {
+	configVars := helper.ConfigVars()
+	opts := mailmanager.CurrentAddressMatchOptions()
+	mongodb.MigrateRegisteredAddresses(func(address string) string {
+		return mailmanager.NormalizeAddress(address, opts)
+	}, configVars.MongodbURI)
+}
+
+// MigrateOnConfigureUsers continues address setups started before
+// conversations existed as setup.addresses conversations
+func MigrateOnConfigureUsers() {
+	configVars := helper.ConfigVars()
+	mongodb.MigrateOnConfigureUsers(func(lineID string, addresses []string) {
+		if readConversation(lineID) != nil {
+			return
+		}
+		var accepted []string
+		for _, address := range addresses {
+			if address, rejection := validateAddress(lineID, address, accepted); len(rejection) == 0 {
+				accepted = append(accepted, address)
+			}
+		}
+		startConversation(lineID, stateSetupAddresses, map[string][]string{"addresses": accepted})
+	}, configVars.MongodbURI)
+}
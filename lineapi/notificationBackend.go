@@ -0,0 +1,84 @@
+package lineapi
+
+import (
+	"time"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+	"github.com/mshrtsr/mail-notice-linebot/mailmanager"
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+)
+
+// notificationBackend stores notification state and pushes to LINE.
+// Tests replace it to check which mails are pushed, held or dropped.
+type notificationBackend interface {
+	ReadLineUser(lineID string) mongodb.LineUser
+	// ClaimMailObjects returns mailObjects not yet notified to lineID and
+	// marks them notified, so that a message fetched by several sources or
+	// processes at once is pushed only once
+	ClaimMailObjects(lineID string, mailObjects []mailmanager.MailObject) []mailmanager.MailObject
+	// ReleaseMailObjects lets mailObjects be notified again after a failed push
+	ReleaseMailObjects(lineID string, mailObjects []mailmanager.MailObject)
+	// Push pushes a notification of mailObjects to lineID in language
+	Push(lineID string, language string, mailObjects []mailmanager.MailObject) error
+	CreateDeferredNotification(deferredNotification mongodb.DeferredNotification)
+	ReadDeferredNotifications() []mongodb.DeferredNotification
+	// DeleteDeferredNotification reports false when another process took it first
+	DeleteDeferredNotification(deferredNotification mongodb.DeferredNotification) bool
+}
+
+// lineNotificationBackend keeps notification state in MongoDB and pushes with the Messaging API
+type lineNotificationBackend struct {
+	bot *linebot.Client
+	url string
+}
+
+// newLineNotificationBackend connects to the LINE channel of the config vars
+func newLineNotificationBackend() (*lineNotificationBackend, error) {
+	configVars := helper.ConfigVars()
+	bot, err := linebot.New(configVars.LineAPI.ChannelSecret, configVars.LineAPI.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	return &lineNotificationBackend{bot: bot, url: configVars.MongodbURI}, nil
+}
+
+func (b *lineNotificationBackend) ReadLineUser(lineID string) mongodb.LineUser {
+	return mongodb.ReadLineUser(lineID, b.url)
+}
+
+func (b *lineNotificationBackend) ClaimMailObjects(lineID string, mailObjects []mailmanager.MailObject) []mailmanager.MailObject {
+	var claimed []mailmanager.MailObject
+	for _, mailObject := range mailObjects {
+		if mongodb.ClaimNotifiedMessage(mongodb.NotifiedMessage{
+			LineID:     lineID,
+			MessageKey: mailObject.MessageKey,
+			CreatedAt:  time.Now(),
+		}, b.url) {
+			claimed = append(claimed, mailObject)
+		}
+	}
+	return claimed
+}
+
+func (b *lineNotificationBackend) ReleaseMailObjects(lineID string, mailObjects []mailmanager.MailObject) {
+	for _, mailObject := range mailObjects {
+		mongodb.ReleaseNotifiedMessage(lineID, mailObject.MessageKey, b.url)
+	}
+}
+
+func (b *lineNotificationBackend) Push(lineID string, language string, mailObjects []mailmanager.MailObject) error {
+	return pushMailObjects(b.bot, lineID, language, mailObjects)
+}
+
+func (b *lineNotificationBackend) CreateDeferredNotification(deferredNotification mongodb.DeferredNotification) {
+	mongodb.CreateDeferredNotification(deferredNotification, b.url)
+}
+
+func (b *lineNotificationBackend) ReadDeferredNotifications() []mongodb.DeferredNotification {
+	return mongodb.ReadDeferredNotifications(b.url)
+}
+
+func (b *lineNotificationBackend) DeleteDeferredNotification(deferredNotification mongodb.DeferredNotification) bool {
+	return mongodb.DeleteDeferredNotification(deferredNotification.ID, b.url)
+}
@@ -0,0 +1,178 @@
+package lineapi
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+	"github.com/mshrtsr/mail-notice-linebot/mailmanager"
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+)
+
+const (
+	// maxFilters bounds the notification filters of a user
+	maxFilters = 20
+	// maxFilterPatternLength bounds the pattern of a filter in characters
+	maxFilterPatternLength = 100
+)
+
+// filterFields are the fields a notification filter may look at
+var filterFields = []string{mongodb.FilterFieldFrom, mongodb.FilterFieldTo, mongodb.FilterFieldSubject}
+
+// isFilterField reports whether a filter may look at field
+func isFilterField(field string) bool {
+	for _, filterField := range filterFields {
+		if field == filterField {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesFilter reports whether filter skips the mail
+func matchesFilter(filter mongodb.NotificationFilter, mailObject mailmanager.MailObject) bool {
+	var values []string
+	switch filter.Field {
+	case mongodb.FilterFieldFrom:
+		for _, address := range mailObject.MailFrom {
+			values = append(values, address.Name, address.Address)
+		}
+	case mongodb.FilterFieldTo:
+		values = append(values, mailObject.MailReceivedAddress, mailObject.RegisteredAddress)
+	case mongodb.FilterFieldSubject:
+		values = append(values, mailObject.MailSubject)
+	}
+	pattern := strings.ToLower(filter.Pattern)
+	for _, value := range values {
+		if len(pattern) > 0 && strings.Contains(strings.ToLower(value), pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterMailObjects removes mails skipped by any of filters
+func filterMailObjects(filters []mongodb.NotificationFilter, mailObjects []mailmanager.MailObject) []mailmanager.MailObject {
+	var notified []mailmanager.MailObject
+	for _, mailObject := range mailObjects {
+		skipped := false
+		for _, filter := range filters {
+			if matchesFilter(filter, mailObject) {
+				skipped = true
+				break
+			}
+		}
+		if !skipped {
+			notified = append(notified, mailObject)
+		}
+	}
+	return notified
+}
+
+// validateQuietHours checks quiet hours entered by a user
+func validateQuietHours(quietHours mongodb.QuietHours) bool {
+	start, err := time.Parse("15:04", quietHours.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse("15:04", quietHours.End)
+	if err != nil {
+		return false
+	}
+	return !start.Equal(end)
+}
+
+// deferNotification holds mails until the quiet hours of lineID end
+func deferNotification(backend notificationBackend, lineID string, mailObjects []mailmanager.MailObject, now time.Time) {
+	data, err := json.Marshal(mailObjects)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	backend.CreateDeferredNotification(mongodb.DeferredNotification{
+		LineID:      lineID,
+		MailObjects: data,
+		CreatedAt:   now,
+	})
+}
+
+// FlushDeferredNotifications pushes the mails held for users whose quiet hours have ended
+func FlushDeferredNotifications() {
+	backend, err := newLineNotificationBackend()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	flushDeferredNotifications(backend, time.Now().In(timeLocation()))
+}
+
+// flushDeferredNotifications pushes the mails held for users out of their quiet hours at now
+func flushDeferredNotifications(backend notificationBackend, now time.Time) {
+	deferredNotifications := backend.ReadDeferredNotifications()
+	if len(deferredNotifications) == 0 {
+		return
+	}
+
+	// Group by user, keeping the order mails were held
+	var lineIDs []string
+	byLineID := make(map[string][]mongodb.DeferredNotification)
+	for _, deferredNotification := range deferredNotifications {
+		if _, ok := byLineID[deferredNotification.LineID]; !ok {
+			lineIDs = append(lineIDs, deferredNotification.LineID)
+		}
+		byLineID[deferredNotification.LineID] = append(byLineID[deferredNotification.LineID], deferredNotification)
+	}
+
+	for _, lineID := range lineIDs {
+		lineUser := backend.ReadLineUser(lineID)
+		if len(lineUser.LineID) == 0 || len(lineUser.RegisteredAddresses) == 0 || lineUser.Muted {
+			// Revoked and muted users are not notified of mails held before
+			for _, deferredNotification := range byLineID[lineID] {
+				backend.DeleteDeferredNotification(deferredNotification)
+			}
+			continue
+		}
+		if lineUser.QuietHours.Contains(now) {
+			continue
+		}
+
+		var taken []mongodb.DeferredNotification
+		var mailObjects []mailmanager.MailObject
+		for _, deferredNotification := range byLineID[lineID] {
+			// Deleting first keeps another process from pushing it again
+			if !backend.DeleteDeferredNotification(deferredNotification) {
+				continue
+			}
+			var held []mailmanager.MailObject
+			if err := json.Unmarshal(deferredNotification.MailObjects, &held); err != nil {
+				log.Print(err)
+				continue
+			}
+			taken = append(taken, deferredNotification)
+			mailObjects = append(mailObjects, held...)
+		}
+		if len(mailObjects) == 0 {
+			continue
+		}
+		if err := backend.Push(lineID, lineUserLanguage(lineUser), mailObjects); err != nil {
+			log.Print(err)
+			if isPermanentPushError(err) {
+				continue
+			}
+			// Held again as they were, so that the TTL still ends the retries
+			for _, deferredNotification := range taken {
+				backend.CreateDeferredNotification(deferredNotification)
+			}
+		}
+	}
+}
+
+// isPermanentPushError reports whether pushing again cannot succeed, e.g. when
+// the user blocked the bot. Rate limits are temporary.
+func isPermanentPushError(err error) bool {
+	apiErr, ok := err.(*linebot.APIError)
+	return ok && apiErr.Code >= 400 && apiErr.Code < 500 && apiErr.Code != http.StatusTooManyRequests
+}
@@ -0,0 +1,174 @@
+package lineapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/line/line-bot-sdk-go/linebot"
+	"github.com/mshrtsr/mail-notice-linebot/mailmanager"
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+)
+
+func TestFilterMailObjects(t *testing.T) {
+	mailObjects := []mailmanager.MailObject{
+		{MessageKey: "1", MailSubject: "Weekly NEWSLETTER", MailFrom: []mailmanager.MailAddress{{Name: "Shop", Address: "news@shop.example"}}, MailReceivedAddress: "me@example.com"},
+		{MessageKey: "2", MailSubject: "Lunch?", MailFrom: []mailmanager.MailAddress{{Name: "Alice", Address: "alice@example.org"}}, MailReceivedAddress: "me+work@example.com"},
+		{MessageKey: "3", MailSubject: "Invoice", MailFrom: []mailmanager.MailAddress{{Name: "Billing", Address: "billing@example.net"}}, MailReceivedAddress: "me@example.com"},
+	}
+	tests := []struct {
+		name    string
+		filters []mongodb.NotificationFilter
+		want    string
+	}{
+		{name: "no filters", filters: nil, want: "123"},
+		{name: "subject ignoring case", filters: []mongodb.NotificationFilter{{Field: "subject", Pattern: "newsletter"}}, want: "23"},
+		{name: "from name", filters: []mongodb.NotificationFilter{{Field: "from", Pattern: "alice"}}, want: "13"},
+		{name: "from address", filters: []mongodb.NotificationFilter{{Field: "from", Pattern: "@example.net"}}, want: "12"},
+		{name: "to", filters: []mongodb.NotificationFilter{{Field: "to", Pattern: "+work@"}}, want: "13"},
+		{name: "any filter", filters: []mongodb.NotificationFilter{{Field: "subject", Pattern: "invoice"}, {Field: "from", Pattern: "shop"}}, want: "2"},
+		{name: "empty pattern", filters: []mongodb.NotificationFilter{{Field: "subject", Pattern: ""}}, want: "123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			for _, mailObject := range filterMailObjects(tt.filters, mailObjects) {
+				got += mailObject.MessageKey
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// heldNotification is a DeferredNotification of the mails keys created at createdAt
+func heldNotification(t *testing.T, lineID string, keys string, createdAt time.Time) mongodb.DeferredNotification {
+	data, err := json.Marshal(mailObjectsOf(keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mongodb.DeferredNotification{ID: bson.NewObjectId(), LineID: lineID, MailObjects: data, CreatedAt: createdAt}
+}
+
+func TestFlushDeferredNotifications(t *testing.T) {
+	registered := []mongodb.RegisteredAddress{{Address: "taro@example.com"}}
+	tests := []struct {
+		name         string
+		lineUser     mongodb.LineUser
+		pushErr      error
+		wantPushed   []string
+		wantDeferred []string
+	}{
+		{
+			name:       "pushed together after the quiet hours",
+			lineUser:   mongodb.LineUser{LineID: "U1", RegisteredAddresses: registered, QuietHours: testQuietHours},
+			wantPushed: []string{"U1:abc"},
+		},
+		{
+			name:         "kept in the quiet hours",
+			lineUser:     mongodb.LineUser{LineID: "U1", RegisteredAddresses: registered, QuietHours: &mongodb.QuietHours{Start: "07:30", End: "09:00"}},
+			wantDeferred: []string{"U1:ab", "U1:c"},
+		},
+		{
+			name:         "held again as they were after a failed push",
+			lineUser:     mongodb.LineUser{LineID: "U1", RegisteredAddresses: registered},
+			pushErr:      errors.New("connection reset"),
+			wantDeferred: []string{"U1:ab", "U1:c"},
+		},
+		{
+			name:         "held again when rate limited",
+			lineUser:     mongodb.LineUser{LineID: "U1", RegisteredAddresses: registered},
+			pushErr:      &linebot.APIError{Code: http.StatusTooManyRequests},
+			wantDeferred: []string{"U1:ab", "U1:c"},
+		},
+		{
+			name:     "dropped when LINE refuses the push",
+			lineUser: mongodb.LineUser{LineID: "U1", RegisteredAddresses: registered},
+			pushErr:  &linebot.APIError{Code: http.StatusForbidden},
+		},
+		{
+			name: "dropped for a user who no longer exists",
+		},
+		{
+			name:     "dropped for a user without addresses",
+			lineUser: mongodb.LineUser{LineID: "U1", Language: "en"},
+		},
+		{
+			name:     "dropped for a muted user",
+			lineUser: mongodb.LineUser{LineID: "U1", RegisteredAddresses: registered, Muted: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeNotificationBackend(tt.lineUser)
+			backend.pushErr = tt.pushErr
+			held := []mongodb.DeferredNotification{
+				heldNotification(t, "U1", "ab", testNight.Add(-time.Hour)),
+				heldNotification(t, "U1", "c", testNight),
+			}
+			backend.deferred = append(backend.deferred, held...)
+			flushDeferredNotifications(backend, testMorning)
+
+			if !reflect.DeepEqual(backend.pushed, tt.wantPushed) {
+				t.Errorf("pushed %v, want %v", backend.pushed, tt.wantPushed)
+			}
+			if got := backend.deferredKeys(t); !reflect.DeepEqual(got, tt.wantDeferred) {
+				t.Errorf("held %v, want %v", got, tt.wantDeferred)
+			}
+			if len(backend.deferred) > 0 && !reflect.DeepEqual(backend.deferred, held) {
+				// Keeping CreatedAt lets the TTL index end the retries
+				t.Errorf("held again as %v, want %v", backend.deferred, held)
+			}
+		})
+	}
+}
+
+// racingNotificationBackend loses taken to another process right after reading it
+type racingNotificationBackend struct {
+	*fakeNotificationBackend
+	taken mongodb.DeferredNotification
+}
+
+func (b racingNotificationBackend) ReadDeferredNotifications() []mongodb.DeferredNotification {
+	deferredNotifications := b.fakeNotificationBackend.ReadDeferredNotifications()
+	b.fakeNotificationBackend.DeleteDeferredNotification(b.taken)
+	return deferredNotifications
+}
+
+func TestFlushDeferredNotificationsTakenByAnotherProcess(t *testing.T) {
+	backend := racingNotificationBackend{
+		fakeNotificationBackend: newFakeNotificationBackend(mongodb.LineUser{LineID: "U1", RegisteredAddresses: []mongodb.RegisteredAddress{{Address: "taro@example.com"}}}),
+		taken:                   heldNotification(t, "U1", "a", testNight),
+	}
+	backend.deferred = []mongodb.DeferredNotification{backend.taken, heldNotification(t, "U1", "b", testNight)}
+	flushDeferredNotifications(backend, testMorning)
+	if want := []string{"U1:b"}; !reflect.DeepEqual(backend.pushed, want) {
+		t.Errorf("pushed %v, want %v", backend.pushed, want)
+	}
+}
+
+func TestIsPermanentPushError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "bad request", err: &linebot.APIError{Code: http.StatusBadRequest}, want: true},
+		{name: "blocked", err: &linebot.APIError{Code: http.StatusForbidden}, want: true},
+		{name: "rate limited", err: &linebot.APIError{Code: http.StatusTooManyRequests}, want: false},
+		{name: "server error", err: &linebot.APIError{Code: http.StatusInternalServerError}, want: false},
+		{name: "network error", err: errors.New("connection reset"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isPermanentPushError(tt.err); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,43 +3,113 @@ package lineapi
 import (
 	"log"
 	"strconv"
+	"time"
 
 	"github.com/mshrtsr/mail-notice-linebot/helper"
 	"github.com/mshrtsr/mail-notice-linebot/mailmanager"
+	"github.com/emersion/go-imap"
 	"github.com/line/line-bot-sdk-go/linebot"
 )
 
-// SendPushNotification ..
-func SendPushNotification(userMailObjects []mailmanager.UserMailObject) {
+// defaultArchiveMaxBytes keeps archived mails below the MongoDB document limit
+const defaultArchiveMaxBytes = 8 << 20
+
+// maxPushMessages is the number of messages LINE accepts in one push
+const maxPushMessages = 5
+
+// NotifyMessages matches messages against registered addresses and pushes notifications
+func NotifyMessages(messages []imap.Message) {
+	if len(messages) < 1 {
+		return
+	}
 	configVars := helper.ConfigVars()
 
-	//lineChannelID := configVars.LineAPI.ChannelID
-	lineChannelSecret := configVars.LineAPI.ChannelSecret
-	lineAccessToken := configVars.LineAPI.AccessToken
+	userMailObjects := mailmanager.ConvertMessagesToUserMailObject(messages, mailmanager.DefaultAddressIndex(), configVars.MongodbURI)
 
-	bot, err := linebot.New(lineChannelSecret, lineAccessToken)
+	if len(userMailObjects) > 0 {
+		archiveMaxBytes := helper.Int64OrDefault(configVars.Attachment.ArchiveMaxBytes, defaultArchiveMaxBytes)
+		mailmanager.ArchiveMessages(messages, userMailObjects, archiveMaxBytes, configVars.MongodbURI)
+		SendPushNotification(userMailObjects)
+	}
+}
+
+// SendPushNotification ..
+func SendPushNotification(userMailObjects []mailmanager.UserMailObject) {
+	backend, err := newLineNotificationBackend()
 	if err != nil {
 		log.Print(err)
+		return
 	}
+	sendPushNotification(backend, userMailObjects, time.Now().In(timeLocation()))
+}
 
+// sendPushNotification pushes userMailObjects, holding them for users in their quiet hours at now
+func sendPushNotification(backend notificationBackend, userMailObjects []mailmanager.UserMailObject, now time.Time) {
 	for _, userMailObject := range userMailObjects {
-		var textContents string
-		textContents = "新着メールが" + strconv.Itoa(len(userMailObject.MailObjects)) + "件あります\n"
-		for i, mailObject := range userMailObject.MailObjects {
-			if len(userMailObject.MailObjects) > 1 {
-				textContents += strconv.Itoa(i+1) + ".\n"
-			}
-			if len(mailObject.MailFromName) > 0 {
-				textContents += "差出人: " + mailObject.MailFromName + "\n"
-			} else {
-				textContents += "差出人: " + mailObject.MailFromAddress + "\n"
-			}
-			//textContents += "宛先: " + mailObject.MailReceivedAddress + "\n"
-			textContents += "件名: " + mailObject.MailSubject + "\n"
+		lineUser := backend.ReadLineUser(userMailObject.TargetLineID)
+		mailObjects := backend.ClaimMailObjects(userMailObject.TargetLineID, filterMailObjects(lineUser.Filters, userMailObject.MailObjects))
+		if len(mailObjects) == 0 {
+			continue
 		}
-		if _, err := bot.PushMessage(userMailObject.TargetLineID, linebot.NewTextMessage(textContents)).Do(); err != nil {
+
+		if lineUser.QuietHours.Contains(now) {
+			// Pushed by FlushDeferredNotifications when the quiet hours end
+			deferNotification(backend, userMailObject.TargetLineID, mailObjects, now)
+		} else if err := backend.Push(userMailObject.TargetLineID, lineUserLanguage(lineUser), mailObjects); err != nil {
 			log.Print(err)
+			backend.ReleaseMailObjects(userMailObject.TargetLineID, mailObjects)
+		}
+	}
+
+}
+
+// pushMailObjects pushes a notification of mailObjects to lineID in language
+func pushMailObjects(bot *linebot.Client, lineID string, language string, mailObjects []mailmanager.MailObject) error {
+	textContents := renderTemplate(language, "push_notification.txt", templateData{"Mails": mailObjects})
+
+	messages := []linebot.SendingMessage{linebot.NewTextMessage(textContents)}
+	for i, mailObject := range mailObjects {
+		if len(messages) >= maxPushMessages {
+			break
 		}
+		messages = append(messages, mailActionMessage(language, i, len(mailObjects), mailObject))
 	}
 
+	_, err := bot.PushMessage(lineID, messages...).Do()
+	return err
+}
+
+// mailActionMessage builds buttons for actions on a notified mail
+func mailActionMessage(language string, i int, count int, mailObject mailmanager.MailObject) linebot.SendingMessage {
+	readLabel := renderTemplate(language, "label_read_body.txt", nil)
+	replyLabel := renderTemplate(language, "label_reply.txt", nil)
+	actions := []linebot.TemplateAction{
+		linebot.NewPostbackAction(readLabel, readPostbackData(mailObject.MailID), "", readLabel),
+		linebot.NewPostbackAction(replyLabel, replyPostbackData(mailObject.MailID), "", replyLabel),
+	}
+	for _, attachment := range forwardableAttachments(mailObject) {
+		// Buttons template accepts up to 4 actions
+		if len(actions) >= 4 {
+			break
+		}
+		label := truncateText(attachment.Name, 20)
+		actions = append(actions, linebot.NewPostbackAction(label, attachmentPostbackData(mailObject.MailID, attachment.Part), "", label))
+	}
+
+	title := mailObject.MailSubject
+	if len(title) == 0 {
+		title = renderTemplate(language, "mail_no_subject.txt", nil)
+	}
+	if count > 1 {
+		title = strconv.Itoa(i+1) + ". " + title
+	}
+	mark := accountMark(mailObject.AddressColor, mailObject.AddressEmoji)
+	title = mark + title
+	text := renderTemplate(language, "mail_action_text.txt", templateData{
+		"Account": mark + mailObject.AccountName(),
+		"From":    mailObject.MailFromDisplayName(),
+	})
+	altText := truncateText(title, 40)
+	template := linebot.NewButtonsTemplate("", truncateText(title, 40), truncateText(text, 60), actions...)
+	return linebot.NewTemplateMessage(altText, template)
 }
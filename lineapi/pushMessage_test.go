@@ -0,0 +1,200 @@
+package lineapi
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/mshrtsr/mail-notice-linebot/mailmanager"
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+)
+
+// fakeNotificationBackend keeps notification state in memory
+type fakeNotificationBackend struct {
+	lineUsers map[string]mongodb.LineUser
+	notified  map[string]bool
+	pushErr   error
+	// pushed holds the message keys of each push, e.g. "U1:ab"
+	pushed   []string
+	deferred []mongodb.DeferredNotification
+}
+
+func newFakeNotificationBackend(lineUsers ...mongodb.LineUser) *fakeNotificationBackend {
+	b := &fakeNotificationBackend{lineUsers: make(map[string]mongodb.LineUser), notified: make(map[string]bool)}
+	for _, lineUser := range lineUsers {
+		b.lineUsers[lineUser.LineID] = lineUser
+	}
+	return b
+}
+
+func (b *fakeNotificationBackend) ReadLineUser(lineID string) mongodb.LineUser {
+	return b.lineUsers[lineID]
+}
+
+func (b *fakeNotificationBackend) ClaimMailObjects(lineID string, mailObjects []mailmanager.MailObject) []mailmanager.MailObject {
+	var claimed []mailmanager.MailObject
+	for _, mailObject := range mailObjects {
+		if !b.notified[lineID+":"+mailObject.MessageKey] {
+			b.notified[lineID+":"+mailObject.MessageKey] = true
+			claimed = append(claimed, mailObject)
+		}
+	}
+	return claimed
+}
+
+func (b *fakeNotificationBackend) ReleaseMailObjects(lineID string, mailObjects []mailmanager.MailObject) {
+	for _, mailObject := range mailObjects {
+		delete(b.notified, lineID+":"+mailObject.MessageKey)
+	}
+}
+
+func (b *fakeNotificationBackend) Push(lineID string, language string, mailObjects []mailmanager.MailObject) error {
+	if b.pushErr != nil {
+		return b.pushErr
+	}
+	b.pushed = append(b.pushed, lineID+":"+messageKeys(mailObjects))
+	return nil
+}
+
+func (b *fakeNotificationBackend) CreateDeferredNotification(deferredNotification mongodb.DeferredNotification) {
+	if len(deferredNotification.ID) == 0 {
+		deferredNotification.ID = bson.NewObjectId()
+	}
+	b.deferred = append(b.deferred, deferredNotification)
+}
+
+func (b *fakeNotificationBackend) ReadDeferredNotifications() []mongodb.DeferredNotification {
+	return append([]mongodb.DeferredNotification(nil), b.deferred...)
+}
+
+func (b *fakeNotificationBackend) DeleteDeferredNotification(deferredNotification mongodb.DeferredNotification) bool {
+	for i, d := range b.deferred {
+		if d.ID == deferredNotification.ID {
+			b.deferred = append(b.deferred[:i], b.deferred[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// deferredKeys lists the held message keys of each deferred notification, e.g. "U1:ab"
+func (b *fakeNotificationBackend) deferredKeys(t *testing.T) []string {
+	var keys []string
+	for _, deferredNotification := range b.deferred {
+		keys = append(keys, deferredNotification.LineID+":"+messageKeys(heldMailObjects(t, deferredNotification)))
+	}
+	return keys
+}
+
+func heldMailObjects(t *testing.T, deferredNotification mongodb.DeferredNotification) []mailmanager.MailObject {
+	var mailObjects []mailmanager.MailObject
+	if err := json.Unmarshal(deferredNotification.MailObjects, &mailObjects); err != nil {
+		t.Fatal(err)
+	}
+	return mailObjects
+}
+
+func messageKeys(mailObjects []mailmanager.MailObject) string {
+	var keys []string
+	for _, mailObject := range mailObjects {
+		keys = append(keys, mailObject.MessageKey)
+	}
+	return strings.Join(keys, "")
+}
+
+func mailObjectsOf(keys string) []mailmanager.MailObject {
+	var mailObjects []mailmanager.MailObject
+	for _, key := range keys {
+		mailObjects = append(mailObjects, mailmanager.MailObject{MessageKey: string(key), MailSubject: "mail " + string(key)})
+	}
+	return mailObjects
+}
+
+var (
+	// testNight is in testQuietHours
+	testNight      = time.Date(2026, 10, 19, 23, 30, 0, 0, time.UTC)
+	testMorning    = time.Date(2026, 10, 20, 8, 0, 0, 0, time.UTC)
+	testQuietHours = &mongodb.QuietHours{Start: "22:00", End: "07:00"}
+)
+
+func TestSendPushNotification(t *testing.T) {
+	tests := []struct {
+		name         string
+		lineUser     mongodb.LineUser
+		now          time.Time
+		pushErr      error
+		wantPushed   []string
+		wantDeferred []string
+		wantNotified bool
+	}{
+		{
+			name:         "pushed",
+			lineUser:     mongodb.LineUser{LineID: "U1"},
+			now:          testNight,
+			wantPushed:   []string{"U1:ab"},
+			wantNotified: true,
+		},
+		{
+			name:         "pushed out of quiet hours",
+			lineUser:     mongodb.LineUser{LineID: "U1", QuietHours: testQuietHours},
+			now:          testMorning,
+			wantPushed:   []string{"U1:ab"},
+			wantNotified: true,
+		},
+		{
+			name:         "held in quiet hours",
+			lineUser:     mongodb.LineUser{LineID: "U1", QuietHours: testQuietHours},
+			now:          testNight,
+			wantDeferred: []string{"U1:ab"},
+			wantNotified: true,
+		},
+		{
+			name:         "filtered mail is neither pushed nor held",
+			lineUser:     mongodb.LineUser{LineID: "U1", QuietHours: testQuietHours, Filters: []mongodb.NotificationFilter{{Field: "subject", Pattern: "mail a"}}},
+			now:          testNight,
+			wantDeferred: []string{"U1:b"},
+			wantNotified: true,
+		},
+		{
+			name:     "released after a failed push",
+			lineUser: mongodb.LineUser{LineID: "U1"},
+			now:      testMorning,
+			pushErr:  errors.New("connection reset"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeNotificationBackend(tt.lineUser)
+			backend.pushErr = tt.pushErr
+			sendPushNotification(backend, []mailmanager.UserMailObject{{TargetLineID: "U1", MailObjects: mailObjectsOf("ab")}}, tt.now)
+
+			if !reflect.DeepEqual(backend.pushed, tt.wantPushed) {
+				t.Errorf("pushed %v, want %v", backend.pushed, tt.wantPushed)
+			}
+			if got := backend.deferredKeys(t); !reflect.DeepEqual(got, tt.wantDeferred) {
+				t.Errorf("held %v, want %v", got, tt.wantDeferred)
+			}
+			for _, deferredNotification := range backend.deferred {
+				if !deferredNotification.CreatedAt.Equal(tt.now) {
+					t.Errorf("CreatedAt = %v, want %v", deferredNotification.CreatedAt, tt.now)
+				}
+			}
+			if got := backend.notified["U1:b"]; got != tt.wantNotified {
+				t.Errorf("notified = %v, want %v", got, tt.wantNotified)
+			}
+		})
+	}
+}
+
+func TestSendPushNotificationOnlyOnce(t *testing.T) {
+	backend := newFakeNotificationBackend(mongodb.LineUser{LineID: "U1"})
+	sendPushNotification(backend, []mailmanager.UserMailObject{{TargetLineID: "U1", MailObjects: mailObjectsOf("ab")}}, testNight)
+	sendPushNotification(backend, []mailmanager.UserMailObject{{TargetLineID: "U1", MailObjects: mailObjectsOf("bc")}}, testNight)
+	if want := []string{"U1:ab", "U1:c"}; !reflect.DeepEqual(backend.pushed, want) {
+		t.Errorf("pushed %v, want %v", backend.pushed, want)
+	}
+}
@@ -0,0 +1,77 @@
+package lineapi
+
+import (
+	"net/url"
+
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+const (
+	// maxQuickReplyItems is the limit of quick reply buttons in a message
+	maxQuickReplyItems = 13
+	// maxQuickReplyLabelLength is the limit of a quick reply label in characters
+	maxQuickReplyLabelLength = 20
+)
+
+// quickReplyCommands returns the commands offered to lineID outside of a conversation
+func quickReplyCommands(lineID string) []string {
+	configVars := helper.ConfigVars()
+	lineUser := mongodb.ReadLineUser(lineID, configVars.MongodbURI)
+	if len(lineUser.RegisteredAddresses) == 0 {
+		return []string{"add", "help"}
+	}
+	mute := "mute"
+	if lineUser.Muted {
+		mute = "unmute"
+	}
+	return []string{"list", "add", "remove", mute, "help"}
+}
+
+// quickReplyButtons returns the buttons for the current state of lineID.
+// In a conversation they finish or cancel it; otherwise they run commands.
+func quickReplyButtons(lineID string, language string) []*linebot.QuickReplyButton {
+	var buttons []*linebot.QuickReplyButton
+	if conv := readConversation(lineID); conv != nil {
+		if conv.State == stateSetupAddresses {
+			label := renderTemplate(language, "label_finish.txt", nil)
+			buttons = append(buttons, linebot.NewQuickReplyButton("", linebot.NewMessageAction(label, ".")))
+		}
+		// Sent as text so that the conversation cancels itself
+		label := renderTemplate(language, "label_cancel.txt", nil)
+		buttons = append(buttons, linebot.NewQuickReplyButton("", linebot.NewMessageAction(label, keyword(language, "cancel"))))
+		return buttons
+	}
+
+	for _, name := range quickReplyCommands(lineID) {
+		label := renderTemplate(language, "label_command_"+name+".txt", nil)
+		data := url.Values{richMenuCommandKey: {name}}.Encode()
+		buttons = append(buttons, linebot.NewQuickReplyButton("", linebot.NewPostbackAction(label, data, "", label)))
+	}
+	return buttons
+}
+
+// withQuickReplies adds the quick reply buttons for the current state of lineID to message
+func withQuickReplies(lineID string, language string, message linebot.SendingMessage) linebot.SendingMessage {
+	buttons := quickReplyButtons(lineID, language)
+	if len(buttons) == 0 {
+		return message
+	}
+	return message.WithQuickReplies(linebot.NewQuickReplyItems(buttons...))
+}
+
+// addressQuickReplyButtons returns up to max buttons which post "<key>=<address>"
+func addressQuickReplyButtons(addresses []string, key string, max int) []*linebot.QuickReplyButton {
+	var buttons []*linebot.QuickReplyButton
+	for _, address := range addresses {
+		if len(buttons) == max {
+			break
+		}
+		label := truncateText(address, maxQuickReplyLabelLength)
+		data := url.Values{key: {address}}.Encode()
+		buttons = append(buttons, linebot.NewQuickReplyButton("", linebot.NewPostbackAction(label, data, "", address)))
+	}
+	return buttons
+}
@@ -0,0 +1,105 @@
+package lineapi
+
+import (
+	"log"
+	"net/url"
+
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+// sendRemoveAddressChoices shows the registered addresses as quick reply
+// buttons to choose the one to remove
+func sendRemoveAddressChoices(ctx commandContext) {
+	configVars := helper.ConfigVars()
+	lineUser := mongodb.ReadLineUser(ctx.LineID, configVars.MongodbURI)
+	addresses := lineUser.AddressList()
+	if len(addresses) == 0 {
+		sendAddressList(ctx)
+		return
+	}
+	startConversation(ctx.LineID, stateRemovePick, nil)
+
+	// One button per address, and one to remove all addresses
+	buttons := addressQuickReplyButtons(addresses, "remove_address", maxQuickReplyItems-1)
+	allLabel := renderTemplate(ctx.Language, "label_remove_all.txt", nil)
+	buttons = append(buttons, linebot.NewQuickReplyButton("", linebot.NewPostbackAction(allLabel, "remove_all=true", "", allLabel)))
+
+	contentText := renderTemplate(ctx.Language, "remove_choose.txt", templateData{
+		"Addresses": addresses,
+		"More":      len(addresses) - (len(buttons) - 1),
+		"Command":   commandPrefix + "remove",
+	})
+	message := linebot.NewTextMessage(contentText).WithQuickReplies(linebot.NewQuickReplyItems(buttons...))
+	// Send messages
+	if _, err := ctx.Bot.ReplyMessage(ctx.ReplyToken, message).Do(); err != nil {
+		log.Print(err)
+	}
+}
+
+// onRemovePickText accepts a registered address typed instead of tapped
+func onRemovePickText(ctx commandContext, conv *conversation, text string) bool {
+	address := registeredAddressOf(ctx.LineID, text)
+	if len(address) == 0 {
+		return false
+	}
+	confirmRemoveAddress(ctx, conv, address)
+	return true
+}
+
+// onRemovePickPostback takes the chosen address
+func onRemovePickPostback(ctx commandContext, conv *conversation, query url.Values) bool {
+	switch {
+	case len(query.Get("remove_address")) > 0:
+		confirmRemoveAddress(ctx, conv, query.Get("remove_address"))
+	case query.Get("remove_all") == "true":
+		// Continues in the revoke flow
+		conv.end()
+		SendConfirmRevokeForwarding(ctx.Bot, ctx.ReplyToken, ctx.LineID)
+	default:
+		return false
+	}
+	return true
+}
+
+// confirmRemoveAddress asks to confirm removing address
+func confirmRemoveAddress(ctx commandContext, conv *conversation, address string) {
+	conv.set("address", address)
+	conv.transition(stateRemoveConfirm)
+
+	// Confirm template message
+	altText := truncateText(renderTemplate(ctx.Language, "confirm_remove.txt", templateData{"Address": address}), 240)
+	leftBtn, rightBtn := confirmActions(ctx.Language, "remove_confirm")
+	template := linebot.NewConfirmTemplate(altText, leftBtn, rightBtn)
+	message := linebot.NewTemplateMessage(altText, template)
+	// Send messages
+	if _, err := ctx.Bot.ReplyMessage(ctx.ReplyToken, message).Do(); err != nil {
+		log.Print(err)
+	}
+}
+
+// onRemoveConfirmPostback removes the chosen address when confirmed
+func onRemoveConfirmPostback(ctx commandContext, conv *conversation, query url.Values) bool {
+	configVars := helper.ConfigVars()
+
+	switch query.Get("remove_confirm") {
+	case "true":
+		conv.end()
+		address := conv.get("address")
+		var removed, notFound []string
+		if mongodb.DeleteRegisteredAddress(ctx.LineID, address, configVars.MongodbURI) {
+			removed = append(removed, address)
+		} else {
+			notFound = append(notFound, address)
+		}
+		replyText(ctx, renderTemplate(ctx.Language, "address_removed.txt", templateData{"Removed": removed, "NotFound": notFound}))
+	case "false":
+		conv.end()
+		replyText(ctx, renderTemplate(ctx.Language, "canceled.txt", nil))
+	default:
+		return false
+	}
+	return true
+}
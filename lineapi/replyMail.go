@@ -0,0 +1,145 @@
+package lineapi
+
+import (
+	"log"
+	"net/mail"
+	"net/url"
+
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+	"github.com/mshrtsr/mail-notice-linebot/mailmanager"
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+// replyPostbackData ..
+func replyPostbackData(mailID string) string {
+	return "reply=" + mailID
+}
+
+// StartReplyMail starts writing a reply to an archived mail
+func StartReplyMail(bot *linebot.Client, replyToken string, lineID string, mailID string) {
+	configVars := helper.ConfigVars()
+	language := userLanguage(lineID)
+
+	var contentText string
+	mailArchive := mongodb.ReadMailArchive(mailID, configVars.MongodbURI)
+	if !mailArchiveReadableBy(mailArchive, lineID) || len(mailArchive.Raw) == 0 {
+		contentText = renderTemplate(language, "reply_expired.txt", nil)
+	} else if target, err := mailmanager.NewReplyTarget(mailArchive.Raw); err != nil {
+		log.Print(err)
+		contentText = renderTemplate(language, "reply_unavailable.txt", nil)
+	} else {
+		startConversation(lineID, stateReplyText, map[string][]string{"mail_id": {mailID}})
+		contentText = renderTemplate(language, "reply_prompt.txt", templateData{"To": target.To.String(), "CancelWord": keyword(language, "cancel")})
+	}
+
+	message := linebot.NewTextMessage(contentText)
+	// Send messages
+	if _, err := bot.ReplyMessage(replyToken, message).Do(); err != nil {
+		log.Print(err)
+	}
+}
+
+// onReplyText takes text as the reply body and asks to confirm sending it
+func onReplyText(ctx commandContext, conv *conversation, text string) bool {
+	conv.set("text", text)
+	conv.transition(stateReplyConfirm)
+
+	// Confirm template message
+	altText := renderTemplate(ctx.Language, "reply_confirm.txt", nil)
+	sendLabel := renderTemplate(ctx.Language, "label_send.txt", nil)
+	cancelLabel := renderTemplate(ctx.Language, "label_cancel.txt", nil)
+	leftBtn := linebot.NewPostbackAction(sendLabel, "reply_send=true", "", sendLabel)
+	rightBtn := linebot.NewPostbackAction(cancelLabel, "reply_send=false", "", cancelLabel)
+	template := linebot.NewConfirmTemplate(altText, leftBtn, rightBtn)
+	messages := []linebot.SendingMessage{
+		linebot.NewTextMessage(truncateText(text, maxTextLength)),
+		linebot.NewTemplateMessage(altText, template),
+	}
+
+	// Send messages
+	if _, err := ctx.Bot.ReplyMessage(ctx.ReplyToken, messages...).Do(); err != nil {
+		log.Print(err)
+	}
+	return true
+}
+
+// onReplyConfirmPostback sends or discards the reply
+func onReplyConfirmPostback(ctx commandContext, conv *conversation, query url.Values) bool {
+	configVars := helper.ConfigVars()
+
+	answer := query.Get("reply_send")
+	if len(answer) == 0 {
+		return false
+	}
+	conv.end()
+	if answer != "true" {
+		replyText(ctx, renderTemplate(ctx.Language, "reply_canceled.txt", nil))
+		return true
+	}
+
+	var contentText string
+	text := conv.get("text")
+	mailArchive := mongodb.ReadMailArchive(conv.get("mail_id"), configVars.MongodbURI)
+	if len(text) == 0 {
+		contentText = renderTemplate(ctx.Language, "reply_nothing.txt", nil)
+	} else if !mailArchiveReadableBy(mailArchive, ctx.LineID) || len(mailArchive.Raw) == 0 {
+		contentText = renderTemplate(ctx.Language, "reply_expired.txt", nil)
+	} else if target, err := mailmanager.NewReplyTarget(mailArchive.Raw); err != nil {
+		log.Print(err)
+		contentText = renderTemplate(ctx.Language, "reply_unavailable.txt", nil)
+	} else if err := sendReplyMail(ctx.LineID, target, text); err != nil {
+		log.Print(err)
+		contentText = renderTemplate(ctx.Language, "reply_failed.txt", templateData{"To": target.To.String()})
+	} else {
+		contentText = renderTemplate(ctx.Language, "reply_sent.txt", templateData{"To": target.To.String()})
+	}
+	replyText(ctx, contentText)
+	return true
+}
+
+// sendReplyMail sends the reply from the registered address when permitted,
+// otherwise from SENDER_ADDRESS with Reply-To set to the registered address.
+func sendReplyMail(lineID string, target mailmanager.ReplyTarget, text string) error {
+	configVars := helper.ConfigVars()
+
+	sender := mail.Address{Name: configVars.SMTP.SenderUsername, Address: configVars.SMTP.SenderAddress}
+	msg := &mailmanager.OutgoingMessage{
+		From:    sender,
+		To:      []mail.Address{{Name: target.To.Name, Address: target.To.Address}},
+		Subject: target.Subject,
+		Text:    text,
+		Charset: configVars.SMTP.Charset,
+		Headers: map[string]string{
+			"In-Reply-To": target.InReplyTo,
+			"References":  target.References,
+		},
+	}
+	if registeredAddress := replyFromAddress(lineID, target); len(registeredAddress) > 0 {
+		if configVars.SMTP.AllowRegisteredFrom == "true" {
+			msg.From = mail.Address{Address: registeredAddress}
+			msg.Sender = &sender
+		} else {
+			msg.ReplyTo = []mail.Address{{Address: registeredAddress}}
+		}
+	}
+
+	return mailmanager.CurrentMailer().Send(msg)
+}
+
+// replyFromAddress returns the original recipient registered by lineID
+func replyFromAddress(lineID string, target mailmanager.ReplyTarget) string {
+	configVars := helper.ConfigVars()
+	matchOptions := mailmanager.CurrentAddressMatchOptions()
+
+	lineUser := mongodb.ReadLineUser(lineID, configVars.MongodbURI)
+	for _, recipient := range target.Recipients {
+		for _, registeredAddress := range lineUser.RegisteredAddresses {
+			if mailmanager.MatchAddress(registeredAddress.Address, recipient.Address, matchOptions) {
+				return recipient.Address
+			}
+		}
+	}
+	return ""
+}
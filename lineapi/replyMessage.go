@@ -3,11 +3,11 @@ package lineapi
 import (
 	"log"
 	"math/rand"
-	"strconv"
+	"net/url"
 	"strings"
-	"time"
 
 	"github.com/mshrtsr/mail-notice-linebot/helper"
+	"github.com/mshrtsr/mail-notice-linebot/mailmanager"
 	"github.com/mshrtsr/mail-notice-linebot/mongodb"
 	"github.com/line/line-bot-sdk-go/linebot"
 )
@@ -19,26 +19,16 @@ func SendConfirmSetupForwarding(bot *linebot.Client, replyToken string, lineID s
 
 	configVars := helper.ConfigVars()
 	lineUser := mongodb.ReadLineUser(lineID, configVars.MongodbURI)
-	addresses := lineUser.RegisteredAddresses
+	language := lineUserLanguage(lineUser)
+	addresses := lineUser.AddressList()
 
 	// Current e-mail addresses
-	var textContents = "こんにちは！メールお知らせくんです。\n"
-	if len(addresses) > 0 {
-		textContents = textContents + "現在お知らせ設定されているメールアドレスは\n" + strings.Join(addresses, "\n") + "\nです"
-	} else {
-		textContents = textContents + "現在お知らせ設定されているメールアドレスはありません"
-	}
+	textContents := renderTemplate(language, "registered_addresses.txt", templateData{"Addresses": describeAddresses(language, lineUser), "Muted": lineUser.Muted})
 	messages = append(messages, linebot.NewTextMessage(textContents))
 
 	// Confirm template message
-	var altText string
-	if len(addresses) > 0 {
-		altText = "メールお知らせを再設定しますか？"
-	} else {
-		altText = "メールお知らせを設定しますか？"
-	}
-	leftBtn := linebot.NewPostbackAction("はい", "setup=true", "", "はい")
-	rightBtn := linebot.NewPostbackAction("いいえ", "setup=false", "", "いいえ")
+	altText := renderTemplate(language, "confirm_setup.txt", templateData{"Addresses": addresses})
+	leftBtn, rightBtn := confirmActions(language, "setup")
 	template := linebot.NewConfirmTemplate(altText, leftBtn, rightBtn)
 	messages = append(messages, linebot.NewTemplateMessage(altText, template))
 
@@ -55,27 +45,22 @@ func SendConfirmRevokeForwarding(bot *linebot.Client, replyToken string, lineID
 
 	configVars := helper.ConfigVars()
 	lineUser := mongodb.ReadLineUser(lineID, configVars.MongodbURI)
-	addresses := lineUser.RegisteredAddresses
+	language := lineUserLanguage(lineUser)
+	addresses := lineUser.AddressList()
 
 	// Current e-mail addresses
-	var textContents = "こんにちは！メールお知らせくんです。\n"
-	if len(addresses) > 0 {
-		textContents = textContents + "現在お知らせ設定されているメールアドレスは\n" + strings.Join(addresses, "\n") + "\nです"
-	} else {
-		textContents = textContents + "現在お知らせ設定されているメールアドレスはありません"
-	}
+	textContents := renderTemplate(language, "registered_addresses.txt", templateData{"Addresses": describeAddresses(language, lineUser), "Muted": lineUser.Muted})
 	messages = append(messages, linebot.NewTextMessage(textContents))
 
-	if len(addresses) <= 0 {
-		return
-	}
+	if len(addresses) > 0 {
+		startConversation(lineID, stateRevokeConfirm, nil)
 
-	// Confirm template message
-	altText := "メールお知らせを解除しますか？"
-	leftBtn := linebot.NewPostbackAction("はい", "revoke=true", "", "はい")
-	rightBtn := linebot.NewPostbackAction("いいえ", "revoke=false", "", "いいえ")
-	template := linebot.NewConfirmTemplate(altText, leftBtn, rightBtn)
-	messages = append(messages, linebot.NewTemplateMessage(altText, template))
+		// Confirm template message
+		altText := renderTemplate(language, "confirm_revoke.txt", nil)
+		leftBtn, rightBtn := confirmActions(language, "revoke")
+		template := linebot.NewConfirmTemplate(altText, leftBtn, rightBtn)
+		messages = append(messages, linebot.NewTemplateMessage(altText, template))
+	}
 
 	// Send messages
 	if _, err := bot.ReplyMessage(replyToken, messages...).Do(); err != nil {
@@ -84,16 +69,11 @@ func SendConfirmRevokeForwarding(bot *linebot.Client, replyToken string, lineID
 }
 
 // SendRandomReply ..
-func SendRandomReply(bot *linebot.Client, replyToken string) {
-	contentPatterns := []string{
-		"ごめんなさい！よく分かりませんでした！",
-		"「メールお知らせくん」と呼んでいただければメールお知らせ設定が確認できます",
-		"「お知らせ解除」と言っていただければメールお知らせを解除できます",
-		"新しいメールはたぶんありません！",
-	}
+func SendRandomReply(bot *linebot.Client, replyToken string, lineID string, language string) {
+	contentPatterns := strings.Split(renderTemplate(language, "random_reply.txt", nil), "\n")
 	// Randomize reply
 	i := rand.Intn(len(contentPatterns))
-	message := linebot.NewTextMessage(contentPatterns[i])
+	message := withQuickReplies(lineID, language, linebot.NewTextMessage(contentPatterns[i]))
 	// Send messages
 	if _, err := bot.ReplyMessage(replyToken, message).Do(); err != nil {
 		log.Print(err)
@@ -101,19 +81,17 @@ func SendRandomReply(bot *linebot.Client, replyToken string) {
 }
 
 // SendIntroduction ..
-func SendIntroduction(bot *linebot.Client, replyToken string) {
+func SendIntroduction(bot *linebot.Client, replyToken string, language string) {
 	// Send Greeting and introduction
 	var messages []linebot.SendingMessage
 
 	// Greeting
-	var textContents = "登録ありがとうございます！メールお知らせくんです。\n"
-	textContents += "登録されたメールアドレスにメールが届くとお知らせします。\n"
+	textContents := renderTemplate(language, "introduction.txt", nil)
 	messages = append(messages, linebot.NewTextMessage(textContents))
 
 	// Confirm template message
-	altText := "メールお知らせを設定しますか？"
-	leftBtn := linebot.NewPostbackAction("はい", "setup=true", "", "はい")
-	rightBtn := linebot.NewPostbackAction("いいえ", "setup=false", "", "いいえ")
+	altText := renderTemplate(language, "confirm_setup.txt", nil)
+	leftBtn, rightBtn := confirmActions(language, "setup")
 	template := linebot.NewConfirmTemplate(altText, leftBtn, rightBtn)
 	messages = append(messages, linebot.NewTemplateMessage(altText, template))
 
@@ -123,13 +101,28 @@ func SendIntroduction(bot *linebot.Client, replyToken string) {
 	}
 }
 
+// confirmActions returns yes/no buttons which post "<key>=true" and "<key>=false"
+func confirmActions(language, key string) (linebot.TemplateAction, linebot.TemplateAction) {
+	yes := renderTemplate(language, "label_yes.txt", nil)
+	no := renderTemplate(language, "label_no.txt", nil)
+	return linebot.NewPostbackAction(yes, key+"=true", "", yes), linebot.NewPostbackAction(no, key+"=false", "", no)
+}
+
 // RevokeRegisteredUser ..
 func RevokeRegisteredUser(bot *linebot.Client, replyToken string, lineID string) {
 	configVars := helper.ConfigVars()
+	lineUser := mongodb.ReadLineUser(lineID, configVars.MongodbURI)
+	language := lineUserLanguage(lineUser)
 	mongodb.DeleteLineUser(lineID, configVars.MongodbURI)
+	mongodb.DeleteDeferredNotificationsOf(lineID, configVars.MongodbURI)
+	endConversation(lineID)
 
 	if len(replyToken) > 0 {
-		contentText := "お知らせ設定を削除しました！"
+		// The user is still a friend, so the chosen language is kept
+		if len(lineUser.Language) > 0 {
+			mongodb.UpdateLineUserLanguage(lineID, lineUser.Language, configVars.MongodbURI)
+		}
+		contentText := renderTemplate(language, "revoked.txt", nil)
 		message := linebot.NewTextMessage(contentText)
 		// Send messages
 		if _, err := bot.ReplyMessage(replyToken, message).Do(); err != nil {
@@ -140,63 +133,189 @@ func RevokeRegisteredUser(bot *linebot.Client, replyToken string, lineID string)
 
 // StartConfigureAddress ..
 func StartConfigureAddress(bot *linebot.Client, replyToken string, lineID string) {
-	configVars := helper.ConfigVars()
-	ocUser := mongodb.ReadOnConfigureUser(lineID, configVars.MongodbURI)
-	if ocUser.LineID == lineID {
-		contentText := "すでに設定中です\n終了するには「.」を入力してください"
-		message := linebot.NewTextMessage(contentText)
-		// Send messages
-		if _, err := bot.ReplyMessage(replyToken, message).Do(); err != nil {
-			log.Print(err)
-		}
-		return
-	}
-	ocUser = mongodb.OnConfigureUser{
-		LineID:    lineID,
-		CreatedAt: time.Now(),
+	language := userLanguage(lineID)
+	contentText := renderTemplate(language, "configure_already_started.txt", nil)
+	if conv := readConversation(lineID); conv == nil || conv.State != stateSetupAddresses {
+		startConversation(lineID, stateSetupAddresses, nil)
+		contentText = renderTemplate(language, "configure_started.txt", nil)
 	}
-	mongodb.CreateOrUpdateOnConfigureUser(ocUser, configVars.MongodbURI)
-	contentText := "メールアドレスを１件ずつ入力してください\n終了するには「.」を入力してください"
-	message := linebot.NewTextMessage(contentText)
+	message := withQuickReplies(lineID, language, linebot.NewTextMessage(contentText))
 	// Send messages
 	if _, err := bot.ReplyMessage(replyToken, message).Do(); err != nil {
 		log.Print(err)
 	}
 }
 
-// PushAddressToConfigureQueue ..
-func PushAddressToConfigureQueue(bot *linebot.Client, replyToken string, lineID string, address string) {
+// onSetupAddressText takes addresses one by one until "."
+func onSetupAddressText(ctx commandContext, conv *conversation, text string) bool {
+	switch {
+	case strings.TrimSpace(text) == ".":
+		addresses := conv.Values["addresses"]
+		contentText := sendVerificationMails(ctx.LineID, ctx.Language, addresses)
+		if len(addresses) > 0 {
+			// Wait for the verification codes
+			conv.Values = map[string][]string{"addresses": addresses}
+			conv.transition(stateVerifyCode)
+		} else {
+			conv.end()
+		}
+		replyText(ctx, contentText)
+		return true
+	case isCommand(text):
+		// Commands still work while entering addresses
+		return false
+	default:
+		address, rejection := validateAddress(ctx.LineID, text, conv.Values["addresses"])
+		if len(rejection) > 0 {
+			replyText(ctx, renderTemplate(ctx.Language, rejection, templateData{"Address": address}))
+			return true
+		}
+		conv.Values["addresses"] = append(conv.Values["addresses"], address)
+		conv.save()
+		replyText(ctx, renderTemplate(ctx.Language, "address_accepted.txt", templateData{"Address": address, "Count": len(conv.Values["addresses"])}))
+		return true
+	}
+}
+
+// onVerificationCodeText verifies codes until every address is verified
+func onVerificationCodeText(ctx commandContext, conv *conversation, text string) bool {
+	if !isVerificationCode(text) {
+		return false
+	}
+	address, contentText, ok := verifyCode(ctx, text)
+	if ok {
+		var pending []string
+		for _, a := range conv.Values["addresses"] {
+			if a != address {
+				pending = append(pending, a)
+			}
+		}
+		if len(pending) == 0 {
+			conv.end()
+		} else {
+			conv.Values["addresses"] = pending
+			conv.save()
+		}
+	}
+	replyText(ctx, contentText)
+	return true
+}
+
+// isVerificationCode reports whether text looks like a verification code
+func isVerificationCode(text string) bool {
+	return strings.HasPrefix(strings.TrimSpace(text), "VC-")
+}
+
+// verifyCode verifies a code and returns the verified address and the result text
+func verifyCode(ctx commandContext, code string) (string, string, bool) {
 	configVars := helper.ConfigVars()
-	ocUser := mongodb.ReadOnConfigureUser(lineID, configVars.MongodbURI)
-	if ocUser.LineID == lineID {
-		ocUser.Addresses = append(ocUser.Addresses, address)
-		mongodb.CreateOrUpdateOnConfigureUser(ocUser, configVars.MongodbURI)
+	address, err := VerifyAddress(ctx.LineID, strings.TrimSpace(code))
+	if err != nil {
+		return "", err.Error(), false
+	}
+	return address, renderTemplate(ctx.Language, "address_verified.txt", templateData{"Address": address, "ForwardingAddress": configVars.IMAP.Address}), true
+}
+
+// replyVerification verifies a code and replies with the result
+func replyVerification(ctx commandContext, code string) {
+	_, contentText, _ := verifyCode(ctx, code)
+	replyText(ctx, contentText)
+}
+
+// onRevokeConfirmPostback revokes all addresses when confirmed
+func onRevokeConfirmPostback(ctx commandContext, conv *conversation, query url.Values) bool {
+	switch query.Get("revoke") {
+	case "true":
+		conv.end()
+		RevokeRegisteredUser(ctx.Bot, ctx.ReplyToken, ctx.LineID)
+	case "false":
+		conv.end()
+	default:
+		return false
+	}
+	return true
+}
+
+// sendVerificationMails sends verification codes to addresses and returns the result text
+func sendVerificationMails(lineID string, language string, addresses []string) string {
+	var sent, failed []string
+	for _, address := range addresses {
+		verificationCode := GenerateVerificationCode(lineID, address)
+		recipient := mailmanager.VerificationRecipient(address)
+		label := address
+		if recipient != address {
+			label = address + " (" + recipient + ")"
+		}
+		if err := SendVerificationMail(language, "", recipient, verificationCode); err != nil {
+			log.Print(err)
+			failed = append(failed, label)
+			continue
+		}
+		sent = append(sent, label)
 	}
+	return renderTemplate(language, "configure_finished.txt", templateData{"Sent": sent, "Failed": failed})
 }
 
-// FinishConfigureAddress ..
-func FinishConfigureAddress(bot *linebot.Client, replyToken string, lineID string) {
+// sendAddressList replies with the registered addresses
+func sendAddressList(ctx commandContext) {
 	configVars := helper.ConfigVars()
-	ocUser := mongodb.ReadOnConfigureUser(lineID, configVars.MongodbURI)
-	if ocUser.LineID != lineID {
+	lineUser := mongodb.ReadLineUser(ctx.LineID, configVars.MongodbURI)
+	replyText(ctx, renderTemplate(ctx.Language, "address_list.txt", templateData{
+		"Addresses": describeAddresses(ctx.Language, lineUser),
+		"Muted":     lineUser.Muted,
+	}))
+}
+
+// addAddresses sends verification codes to the addresses in the arguments,
+// or starts entering addresses one by one
+func addAddresses(ctx commandContext) {
+	if len(ctx.Args) == 0 {
+		StartConfigureAddress(ctx.Bot, ctx.ReplyToken, ctx.LineID)
 		return
 	}
+	var addresses, lines []string
+	for _, arg := range ctx.Args {
+		address, rejection := validateAddress(ctx.LineID, arg, addresses)
+		if len(rejection) > 0 {
+			lines = append(lines, renderTemplate(ctx.Language, rejection, templateData{"Address": address}))
+			continue
+		}
+		addresses = append(addresses, address)
+	}
+	if len(addresses) > 0 || len(lines) == 0 {
+		lines = append(lines, sendVerificationMails(ctx.LineID, ctx.Language, addresses))
+	}
+	replyText(ctx, strings.Join(lines, "\n"))
+}
 
-	var contentText string
-	if len(ocUser.Addresses) > 0 {
-		contentText = "以下の" + strconv.Itoa(len(ocUser.Addresses)) + "個のメールアドレスに確認コードをお送りしました。メールを確認して確認コードを入力してください\n"
-		for _, address := range ocUser.Addresses {
-			verificationCode := GenerateVerificationCode(ocUser.LineID, address)
-			SendVerificationMail("", address, verificationCode)
-			contentText += address + "\n"
+// removeAddresses removes the addresses in the arguments, or lets the user
+// choose the address to remove
+func removeAddresses(ctx commandContext) {
+	if len(ctx.Args) == 0 {
+		sendRemoveAddressChoices(ctx)
+		return
+	}
+	configVars := helper.ConfigVars()
+	matchOptions := mailmanager.CurrentAddressMatchOptions()
+	var removed, notFound []string
+	for _, arg := range ctx.Args {
+		address := mailmanager.NormalizeAddress(arg, matchOptions)
+		if mongodb.DeleteRegisteredAddress(ctx.LineID, address, configVars.MongodbURI) {
+			removed = append(removed, address)
+		} else {
+			notFound = append(notFound, address)
 		}
-	} else {
-		contentText = "メールアドレスが設定されませんでした"
 	}
-	mongodb.DeleteOnConfigureUser(ocUser.LineID, configVars.MongodbURI)
-	message := linebot.NewTextMessage(contentText)
-	// Send messages
-	if _, err := bot.ReplyMessage(replyToken, message).Do(); err != nil {
-		log.Print(err)
+	replyText(ctx, renderTemplate(ctx.Language, "address_removed.txt", templateData{"Removed": removed, "NotFound": notFound}))
+}
+
+// muteNotification pauses or resumes notifications
+func muteNotification(ctx commandContext, muted bool) {
+	configVars := helper.ConfigVars()
+	mongodb.UpdateLineUserMuted(ctx.LineID, muted, configVars.MongodbURI)
+	if muted {
+		replyText(ctx, renderTemplate(ctx.Language, "muted.txt", nil))
+	} else {
+		replyText(ctx, renderTemplate(ctx.Language, "unmuted.txt", nil))
 	}
 }
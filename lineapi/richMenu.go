@@ -0,0 +1,216 @@
+package lineapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+// richMenuDefinition is the rich menu file given by LINE_RICH_MENU_FILE, e.g.
+//
+//	{
+//	  "name": "mail-notice",
+//	  "chatBarText": "Menu",
+//	  "image": "richmenu.png",
+//	  "size": {"width": 2500, "height": 843},
+//	  "selected": true,
+//	  "areas": [
+//	    {"bounds": {"x": 0, "y": 0, "width": 500, "height": 843}, "command": "list"},
+//	    {"bounds": {"x": 500, "y": 0, "width": 500, "height": 843}, "command": "add"},
+//	    {"bounds": {"x": 1000, "y": 0, "width": 500, "height": 843}, "command": "mute"},
+//	    {"bounds": {"x": 1500, "y": 0, "width": 500, "height": 843},
+//	     "action": {"type": "uri", "uri": "https://liff.line.me/<LIFF ID>"}},
+//	    {"bounds": {"x": 2000, "y": 0, "width": 500, "height": 843}, "command": "help"}
+//	  ]
+//	}
+//
+// The image path is relative to the file. An area runs a chat command with
+// "command", or has any rich menu "action" instead, like the "uri" action
+// above opening the settings page.
+type richMenuDefinition struct {
+	Name        string                   `json:"name"`
+	ChatBarText string                   `json:"chatBarText"`
+	Image       string                   `json:"image"`
+	Size        linebot.RichMenuSize     `json:"size"`
+	Selected    bool                     `json:"selected"`
+	Areas       []richMenuAreaDefinition `json:"areas"`
+}
+
+// richMenuAreaDefinition is a tappable area of the rich menu
+type richMenuAreaDefinition struct {
+	Bounds  linebot.RichMenuBounds  `json:"bounds"`
+	Command string                  `json:"command,omitempty"`
+	Action  *linebot.RichMenuAction `json:"action,omitempty"`
+}
+
+// richMenuCommandKey is the postback key of a command area
+const richMenuCommandKey = "command"
+
+// RichMenuManager keeps the rich menu created from the definition file
+type RichMenuManager struct {
+	mu         sync.RWMutex
+	richMenuID string
+}
+
+var defaultRichMenuManager = &RichMenuManager{}
+
+// DefaultRichMenuManager returns the process wide RichMenuManager
+func DefaultRichMenuManager() *RichMenuManager {
+	return defaultRichMenuManager
+}
+
+// RichMenuID returns the provisioned rich menu, or "" when there is none
+func (m *RichMenuManager) RichMenuID() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.richMenuID
+}
+
+// Provision creates the rich menu of the definition file and uploads its
+// image. A rich menu with the same definition and image is reused. It is made
+// the default rich menu before older versions of it are deleted.
+func (m *RichMenuManager) Provision(bot *linebot.Client, path string) error {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var definition richMenuDefinition
+	if err := json.Unmarshal(source, &definition); err != nil {
+		return err
+	}
+	if len(definition.Name) == 0 || len(definition.Image) == 0 {
+		return errors.New("rich menu: name and image are required")
+	}
+	richMenu, err := definition.richMenu()
+	if err != nil {
+		return err
+	}
+	imagePath := definition.Image
+	if !filepath.IsAbs(imagePath) {
+		imagePath = filepath.Join(filepath.Dir(path), imagePath)
+	}
+	image, err := ioutil.ReadFile(imagePath)
+	if err != nil {
+		return err
+	}
+
+	// The version tells changed definitions apart
+	hash := sha256.New()
+	hash.Write(source)
+	hash.Write(image)
+	richMenu.Name = definition.Name + " #" + hex.EncodeToString(hash.Sum(nil))[:8]
+
+	existing, err := bot.GetRichMenuList().Do()
+	if err != nil {
+		return err
+	}
+	richMenuID := ""
+	for _, res := range existing {
+		if res.Name == richMenu.Name {
+			richMenuID = res.RichMenuID
+		}
+	}
+	if len(richMenuID) == 0 {
+		res, err := bot.CreateRichMenu(richMenu).Do()
+		if err != nil {
+			return err
+		}
+		if _, err := bot.UploadRichMenuImage(res.RichMenuID, imagePath).Do(); err != nil {
+			if _, err := bot.DeleteRichMenu(res.RichMenuID).Do(); err != nil {
+				log.Print(err)
+			}
+			return err
+		}
+		richMenuID = res.RichMenuID
+		log.Println("Created rich menu " + richMenu.Name)
+	}
+
+	// Show the menu to every user, including those who followed before it
+	// existed. Users linked to a deleted version fall back to the default.
+	if _, err := bot.SetDefaultRichMenu(richMenuID).Do(); err != nil {
+		return err
+	}
+
+	// Delete older versions
+	for _, res := range existing {
+		if res.RichMenuID != richMenuID && strings.HasPrefix(res.Name, definition.Name+" #") {
+			if _, err := bot.DeleteRichMenu(res.RichMenuID).Do(); err != nil {
+				log.Print(err)
+				continue
+			}
+			log.Println("Deleted rich menu " + res.Name)
+		}
+	}
+
+	m.mu.Lock()
+	m.richMenuID = richMenuID
+	m.mu.Unlock()
+	return nil
+}
+
+// Link shows the rich menu to a user
+func (m *RichMenuManager) Link(bot *linebot.Client, userID string) {
+	richMenuID := m.RichMenuID()
+	if len(richMenuID) == 0 {
+		return
+	}
+	if _, err := bot.LinkUserRichMenu(userID, richMenuID).Do(); err != nil {
+		log.Print(err)
+	}
+}
+
+// richMenu converts the definition to the request of the LINE API
+func (d richMenuDefinition) richMenu() (linebot.RichMenu, error) {
+	richMenu := linebot.RichMenu{
+		Size:        d.Size,
+		Selected:    d.Selected,
+		ChatBarText: d.ChatBarText,
+	}
+	for _, area := range d.Areas {
+		var action linebot.RichMenuAction
+		switch {
+		case area.Action != nil:
+			action = *area.Action
+		case len(area.Command) > 0:
+			if findCommand(area.Command) == nil {
+				return richMenu, errors.New("rich menu: unknown command " + area.Command)
+			}
+			action = linebot.RichMenuAction{
+				Type: linebot.RichMenuActionTypePostback,
+				Data: url.Values{richMenuCommandKey: {area.Command}}.Encode(),
+			}
+		default:
+			return richMenu, errors.New("rich menu: an area needs a command or an action")
+		}
+		richMenu.Areas = append(richMenu.Areas, linebot.AreaDetail{Bounds: area.Bounds, Action: action})
+	}
+	return richMenu, nil
+}
+
+// ProvisionRichMenu creates the rich menu of LINE_RICH_MENU_FILE if set
+func ProvisionRichMenu() {
+	configVars := helper.ConfigVars()
+	if len(configVars.LineAPI.RichMenuFile) == 0 {
+		return
+	}
+
+	bot, err := linebot.New(configVars.LineAPI.ChannelSecret, configVars.LineAPI.AccessToken)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	if err := DefaultRichMenuManager().Provision(bot, configVars.LineAPI.RichMenuFile); err != nil {
+		log.Print("ProvisionRichMenu: ", err)
+	}
+}
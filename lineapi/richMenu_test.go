@@ -0,0 +1,74 @@
+package lineapi
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+func TestRichMenuDefinition(t *testing.T) {
+	source := `{
+	  "name": "mail-notice",
+	  "chatBarText": "Menu",
+	  "image": "richmenu.png",
+	  "size": {"width": 2500, "height": 843},
+	  "selected": true,
+	  "areas": [
+	    {"bounds": {"x": 0, "y": 0, "width": 1250, "height": 843}, "command": "list"},
+	    {"bounds": {"x": 1250, "y": 0, "width": 1250, "height": 843},
+	     "action": {"type": "uri", "uri": "https://liff.line.me/1234-abcd"}}
+	  ]
+	}`
+	var definition richMenuDefinition
+	if err := json.Unmarshal([]byte(source), &definition); err != nil {
+		t.Fatal(err)
+	}
+	richMenu, err := definition.richMenu()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if richMenu.ChatBarText != "Menu" || !richMenu.Selected || richMenu.Size.Width != 2500 {
+		t.Errorf("richMenu = %+v", richMenu)
+	}
+	if len(richMenu.Areas) != 2 {
+		t.Fatalf("%d areas, want 2", len(richMenu.Areas))
+	}
+
+	command := richMenu.Areas[0]
+	if command.Action.Type != linebot.RichMenuActionTypePostback || command.Bounds.Width != 1250 {
+		t.Errorf("command area = %+v", command)
+	}
+	query, err := url.ParseQuery(command.Action.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Get(richMenuCommandKey) != "list" || findCommand(query.Get(richMenuCommandKey)) == nil {
+		t.Errorf("postback data %q does not run list", command.Action.Data)
+	}
+
+	settings := richMenu.Areas[1]
+	if settings.Action.Type != linebot.RichMenuActionTypeURI || settings.Action.URI != "https://liff.line.me/1234-abcd" || settings.Bounds.X != 1250 {
+		t.Errorf("settings area = %+v", settings)
+	}
+}
+
+func TestRichMenuDefinitionErrors(t *testing.T) {
+	bounds := linebot.RichMenuBounds{Width: 2500, Height: 843}
+	tests := []struct {
+		name string
+		area richMenuAreaDefinition
+	}{
+		{name: "unknown command", area: richMenuAreaDefinition{Bounds: bounds, Command: "dance"}},
+		{name: "neither command nor action", area: richMenuAreaDefinition{Bounds: bounds}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			definition := richMenuDefinition{Name: "mail-notice", Areas: []richMenuAreaDefinition{tt.area}}
+			if _, err := definition.richMenu(); err == nil {
+				t.Error("richMenu() returned no error")
+			}
+		})
+	}
+}
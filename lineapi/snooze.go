@@ -0,0 +1,235 @@
+package lineapi
+
+import (
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+	"github.com/mshrtsr/mail-notice-linebot/mailmanager"
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+// Snooze durations posted as "snooze=<duration>"
+const (
+	snoozeOneHour = "1h"
+	snoozeToday   = "today"
+	// snoozeForever mutes until resumed
+	snoozeForever = "forever"
+)
+
+// snoozeDurations are shown in this order
+var snoozeDurations = []string{snoozeOneHour, snoozeToday, snoozeForever}
+
+// defaultTimeZone decides when "today" ends unless TIME_ZONE is set
+const defaultTimeZone = "Asia/Tokyo"
+
+// timeLocation returns the location of TIME_ZONE
+func timeLocation() *time.Location {
+	configVars := helper.ConfigVars()
+	name := configVars.TimeZone
+	if len(name) == 0 {
+		name = defaultTimeZone
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		log.Print(err)
+		if name == defaultTimeZone {
+			return time.FixedZone("JST", 9*60*60)
+		}
+		return time.Local
+	}
+	return location
+}
+
+// describeAddresses lists the registered addresses of lineUser with their mute state
+func describeAddresses(language string, lineUser mongodb.LineUser) []string {
+	now := time.Now()
+	var lines []string
+	for _, registeredAddress := range lineUser.RegisteredAddresses {
+		if !registeredAddress.IsMuted(now) {
+			lines = append(lines, addressDisplayName(registeredAddress))
+			continue
+		}
+		data := templateData{"Address": addressDisplayName(registeredAddress)}
+		if !registeredAddress.Muted {
+			data["Until"] = formatTime(language, registeredAddress.MutedUntil)
+		}
+		lines = append(lines, renderTemplate(language, "address_snoozed.txt", data))
+	}
+	return lines
+}
+
+// formatTime formats t in TIME_ZONE for language
+func formatTime(language string, t time.Time) string {
+	return t.In(timeLocation()).Format(renderTemplate(language, "time_format.txt", nil))
+}
+
+// snoozeUntil returns when the snooze of duration ends, or zero for snoozeForever
+func snoozeUntil(duration string, now time.Time) (time.Time, bool) {
+	switch duration {
+	case snoozeOneHour:
+		return now.Add(time.Hour), true
+	case snoozeToday:
+		local := now.In(timeLocation())
+		return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, local.Location()), true
+	case snoozeForever:
+		return time.Time{}, true
+	}
+	return time.Time{}, false
+}
+
+// registeredAddressOf returns the registered address typed in text, or ""
+func registeredAddressOf(lineID string, text string) string {
+	configVars := helper.ConfigVars()
+	address := mailmanager.NormalizeAddress(text, mailmanager.CurrentAddressMatchOptions())
+	lineUser := mongodb.ReadLineUser(lineID, configVars.MongodbURI)
+	if lineUser.FindRegisteredAddress(address) == nil {
+		return ""
+	}
+	return address
+}
+
+// snoozeAddress is the "/snooze [address]" command. It asks which address
+// to mute unless given or only one is registered, then asks for how long.
+func snoozeAddress(ctx commandContext) {
+	configVars := helper.ConfigVars()
+	lineUser := mongodb.ReadLineUser(ctx.LineID, configVars.MongodbURI)
+	addresses := lineUser.AddressList()
+
+	switch {
+	case len(ctx.Args) > 0:
+		address := registeredAddressOf(ctx.LineID, ctx.Args[0])
+		if len(address) == 0 {
+			replyText(ctx, renderTemplate(ctx.Language, "address_not_registered.txt", templateData{"Address": ctx.Args[0]}))
+			return
+		}
+		chooseSnoozeDuration(ctx, startConversation(ctx.LineID, stateSnoozeDuration, nil), address)
+	case len(addresses) == 0:
+		sendAddressList(ctx)
+	case len(addresses) == 1:
+		chooseSnoozeDuration(ctx, startConversation(ctx.LineID, stateSnoozeDuration, nil), addresses[0])
+	default:
+		startConversation(ctx.LineID, stateSnoozePick, nil)
+		contentText := renderTemplate(ctx.Language, "snooze_choose_address.txt", nil)
+		buttons := addressQuickReplyButtons(addresses, "snooze_address", maxQuickReplyItems)
+		message := linebot.NewTextMessage(contentText).WithQuickReplies(linebot.NewQuickReplyItems(buttons...))
+		// Send messages
+		if _, err := ctx.Bot.ReplyMessage(ctx.ReplyToken, message).Do(); err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+// onSnoozePickText accepts a registered address typed instead of tapped
+func onSnoozePickText(ctx commandContext, conv *conversation, text string) bool {
+	address := registeredAddressOf(ctx.LineID, text)
+	if len(address) == 0 {
+		return false
+	}
+	chooseSnoozeDuration(ctx, conv, address)
+	return true
+}
+
+// onSnoozePickPostback takes the chosen address
+func onSnoozePickPostback(ctx commandContext, conv *conversation, query url.Values) bool {
+	address := query.Get("snooze_address")
+	if len(address) == 0 {
+		return false
+	}
+	chooseSnoozeDuration(ctx, conv, address)
+	return true
+}
+
+// chooseSnoozeDuration asks for how long to mute address
+func chooseSnoozeDuration(ctx commandContext, conv *conversation, address string) {
+	conv.set("address", address)
+	conv.transition(stateSnoozeDuration)
+
+	var buttons []*linebot.QuickReplyButton
+	for _, duration := range snoozeDurations {
+		label := renderTemplate(ctx.Language, "label_snooze_"+duration+".txt", nil)
+		buttons = append(buttons, linebot.NewQuickReplyButton("", linebot.NewPostbackAction(label, "snooze="+duration, "", label)))
+	}
+	contentText := renderTemplate(ctx.Language, "snooze_choose_duration.txt", templateData{"Address": address})
+	message := linebot.NewTextMessage(contentText).WithQuickReplies(linebot.NewQuickReplyItems(buttons...))
+	// Send messages
+	if _, err := ctx.Bot.ReplyMessage(ctx.ReplyToken, message).Do(); err != nil {
+		log.Print(err)
+	}
+}
+
+// onSnoozeDurationText accepts a duration label typed instead of tapped
+func onSnoozeDurationText(ctx commandContext, conv *conversation, text string) bool {
+	text = strings.TrimSpace(text)
+	for _, duration := range snoozeDurations {
+		label := renderTemplate(ctx.Language, "label_snooze_"+duration+".txt", nil)
+		if strings.EqualFold(text, label) || strings.EqualFold(text, duration) {
+			applySnooze(ctx, conv, duration)
+			return true
+		}
+	}
+	return false
+}
+
+// onSnoozeDurationPostback takes the chosen duration
+func onSnoozeDurationPostback(ctx commandContext, conv *conversation, query url.Values) bool {
+	if _, ok := snoozeUntil(query.Get("snooze"), time.Now()); !ok {
+		return false
+	}
+	applySnooze(ctx, conv, query.Get("snooze"))
+	return true
+}
+
+// applySnooze mutes the chosen address for duration
+func applySnooze(ctx commandContext, conv *conversation, duration string) {
+	configVars := helper.ConfigVars()
+	conv.end()
+
+	address := conv.get("address")
+	until, _ := snoozeUntil(duration, time.Now())
+	if !mongodb.UpdateRegisteredAddressMute(ctx.LineID, address, until.IsZero(), until, configVars.MongodbURI) {
+		replyText(ctx, renderTemplate(ctx.Language, "address_not_registered.txt", templateData{"Address": address}))
+		return
+	}
+	data := templateData{"Address": address}
+	if !until.IsZero() {
+		data["Until"] = formatTime(ctx.Language, until)
+	}
+	replyText(ctx, renderTemplate(ctx.Language, "snoozed.txt", data))
+}
+
+// resumeAddress is the "/resume [address]" command. It unmutes the address,
+// or every muted address when omitted.
+func resumeAddress(ctx commandContext) {
+	configVars := helper.ConfigVars()
+	lineUser := mongodb.ReadLineUser(ctx.LineID, configVars.MongodbURI)
+
+	var targets []string
+	if len(ctx.Args) > 0 {
+		address := registeredAddressOf(ctx.LineID, ctx.Args[0])
+		if len(address) == 0 {
+			replyText(ctx, renderTemplate(ctx.Language, "address_not_registered.txt", templateData{"Address": ctx.Args[0]}))
+			return
+		}
+		targets = append(targets, address)
+	} else {
+		now := time.Now()
+		for _, registeredAddress := range lineUser.RegisteredAddresses {
+			if registeredAddress.IsMuted(now) {
+				targets = append(targets, registeredAddress.Address)
+			}
+		}
+	}
+
+	var resumed []string
+	for _, address := range targets {
+		if mongodb.UpdateRegisteredAddressMute(ctx.LineID, address, false, time.Time{}, configVars.MongodbURI) {
+			resumed = append(resumed, address)
+		}
+	}
+	replyText(ctx, renderTemplate(ctx.Language, "resumed.txt", templateData{"Addresses": resumed}))
+}
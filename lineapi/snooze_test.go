@@ -0,0 +1,73 @@
+package lineapi
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSnoozeUntil(t *testing.T) {
+	os.Setenv("TIME_ZONE", "Asia/Tokyo")
+	defer os.Unsetenv("TIME_ZONE")
+
+	tests := []struct {
+		name     string
+		duration string
+		now      time.Time
+		want     time.Time
+		ok       bool
+	}{
+		{
+			name:     "one hour",
+			duration: snoozeOneHour,
+			now:      time.Date(2020, 4, 1, 14, 30, 0, 0, time.UTC),
+			want:     time.Date(2020, 4, 1, 15, 30, 0, 0, time.UTC),
+			ok:       true,
+		},
+		{
+			// 23:59:59 in Tokyo is still April 1st there
+			name:     "today just before midnight in TIME_ZONE",
+			duration: snoozeToday,
+			now:      time.Date(2020, 4, 1, 14, 59, 59, 0, time.UTC),
+			want:     time.Date(2020, 4, 1, 15, 0, 0, 0, time.UTC),
+			ok:       true,
+		},
+		{
+			// Midnight in Tokyo starts April 2nd although it is April 1st in UTC
+			name:     "today at midnight in TIME_ZONE",
+			duration: snoozeToday,
+			now:      time.Date(2020, 4, 1, 15, 0, 0, 0, time.UTC),
+			want:     time.Date(2020, 4, 2, 15, 0, 0, 0, time.UTC),
+			ok:       true,
+		},
+		{
+			name:     "today at the end of a month",
+			duration: snoozeToday,
+			now:      time.Date(2020, 12, 31, 3, 0, 0, 0, time.UTC),
+			want:     time.Date(2020, 12, 31, 15, 0, 0, 0, time.UTC),
+			ok:       true,
+		},
+		{
+			name:     "forever",
+			duration: snoozeForever,
+			now:      time.Date(2020, 4, 1, 14, 30, 0, 0, time.UTC),
+			want:     time.Time{},
+			ok:       true,
+		},
+		{
+			name:     "unknown",
+			duration: "1y",
+			now:      time.Date(2020, 4, 1, 14, 30, 0, 0, time.UTC),
+			want:     time.Time{},
+			ok:       false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := snoozeUntil(tt.duration, tt.now)
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("snoozeUntil(%q) = %v, %v, want %v, %v", tt.duration, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package lineapi
+
+import (
+	"bytes"
+	"errors"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+)
+
+// templateData is passed to templates
+type templateData map[string]interface{}
+
+// executableTemplate is a text/template or html/template
+type executableTemplate interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+var templateFuncs = map[string]interface{}{
+	"join": strings.Join,
+	"inc":  func(i int) int { return i + 1 },
+	// accountMark shows the color and emoji of a registered address
+	"accountMark": accountMark,
+}
+
+var (
+	templateCache   = make(map[string]executableTemplate)
+	templateCacheMu sync.Mutex
+)
+
+// loadTemplate parses a template of language, looking in order at
+// TEMPLATE_DIR/<language>/<name>, TEMPLATE_DIR/<name> (default language only)
+// and the built-in templates of builtinTemplate.
+// Names ending in .html use html/template.
+// Parsed templates are cached until the process restarts.
+func loadTemplate(language, name string) executableTemplate {
+	templateCacheMu.Lock()
+	key := language + "/" + name
+	t, ok := templateCache[key]
+	templateCacheMu.Unlock()
+	if ok {
+		return t
+	}
+
+	configVars := helper.ConfigVars()
+	var paths []string
+	if len(configVars.TemplateDir) > 0 {
+		paths = append(paths, filepath.Join(configVars.TemplateDir, language, name))
+		if language == defaultLanguage() {
+			paths = append(paths, filepath.Join(configVars.TemplateDir, name))
+		}
+	}
+	for _, path := range paths {
+		source, err := ioutil.ReadFile(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Print(err)
+			}
+			continue
+		}
+		if t, err = parseTemplate(name, string(source)); err != nil {
+			log.Print(err)
+			continue
+		}
+		break
+	}
+	if t == nil {
+		t = builtinTemplate(language, name)
+	}
+	if t == nil {
+		return nil
+	}
+
+	templateCacheMu.Lock()
+	templateCache[key] = t
+	templateCacheMu.Unlock()
+	return t
+}
+
+// builtinTemplate parses the built-in template of language, falling back to
+// the default language and to fallbackLanguage. It logs names found nowhere.
+func builtinTemplate(language, name string) executableTemplate {
+	for _, l := range []string{language, defaultLanguage(), fallbackLanguage} {
+		source, ok := defaultTemplates[l][name]
+		if !ok {
+			continue
+		}
+		t, err := parseTemplate(name, source)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		return t
+	}
+	log.Print("template not found: ", language+"/"+name)
+	return nil
+}
+
+// parseTemplate returns a nil interface, not a nil *Template, on errors
+func parseTemplate(name, source string) (executableTemplate, error) {
+	if strings.HasSuffix(name, ".html") {
+		t, err := htmltemplate.New(name).Funcs(htmltemplate.FuncMap(templateFuncs)).Parse(source)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+	t, err := template.New(name).Funcs(template.FuncMap(templateFuncs)).Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// renderTemplate renders a template. Trailing newlines of text templates are
+// removed so template files may end with a newline. A template in TEMPLATE_DIR
+// which fails to render is replaced by the built-in one.
+func renderTemplate(language, name string, data interface{}) string {
+	if data == nil {
+		data = templateData{}
+	}
+	t := loadTemplate(language, name)
+	text, err := executeTemplate(t, data)
+	if err != nil && t != nil {
+		log.Print("template ", language+"/"+name, ": ", err)
+		if text, err = executeTemplate(builtinTemplate(language, name), data); err != nil {
+			log.Print("template ", language+"/"+name, ": ", err)
+		}
+	}
+	if strings.HasSuffix(name, ".html") {
+		return text
+	}
+	return strings.TrimRight(text, "\r\n")
+}
+
+// executeTemplate renders t, which may be nil when the template is missing
+func executeTemplate(t executableTemplate, data interface{}) (string, error) {
+	if t == nil {
+		return "", errors.New("template not found")
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
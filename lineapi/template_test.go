@@ -0,0 +1,83 @@
+package lineapi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTemplateCatalogs(t *testing.T) {
+	for language, catalog := range defaultTemplates {
+		for name := range defaultTemplates[fallbackLanguage] {
+			if _, ok := catalog[name]; !ok {
+				t.Errorf("%s: %s is missing", language, name)
+			}
+		}
+		for name, source := range catalog {
+			if _, err := parseTemplate(name, source); err != nil {
+				t.Errorf("%s: %v", language, err)
+			}
+		}
+	}
+}
+
+func TestRenderTemplateFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "en"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"en/help_header.txt":     "Custom commands\n",
+		"en/help_help.txt":       "{{index .Missing 3}}",
+		"en/unknown_command.txt": "{{.Command",
+	}
+	for name, source := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(source), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Setenv("TEMPLATE_DIR", dir)
+	defer os.Unsetenv("TEMPLATE_DIR")
+	resetTemplateCache()
+	defer resetTemplateCache()
+
+	tests := []struct {
+		language string
+		name     string
+		data     templateData
+		want     string
+	}{
+		{language: "en", name: "help_header.txt", want: "Custom commands"},
+		{language: "en", name: "help_help.txt", want: defaultTemplates["en"]["help_help.txt"]},
+		{language: "en", name: "unknown_command.txt", data: templateData{"Command": "/x"}, want: renderBuiltin("en", "unknown_command.txt", templateData{"Command": "/x"})},
+		{language: "fr", name: "help_header.txt", want: defaultTemplates[fallbackLanguage]["help_header.txt"]},
+		{language: "en", name: "no_such_template.txt", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.language+"/"+tt.name, func(t *testing.T) {
+			if got := renderTemplate(tt.language, tt.name, tt.data); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func resetTemplateCache() {
+	templateCacheMu.Lock()
+	defer templateCacheMu.Unlock()
+	templateCache = make(map[string]executableTemplate)
+
+	supportedLanguagesCacheMu.Lock()
+	defer supportedLanguagesCacheMu.Unlock()
+	supportedLanguagesCache = nil
+}
+
+func renderBuiltin(language, name string, data templateData) string {
+	text, _ := executeTemplate(builtinTemplate(language, name), data)
+	return text
+}
@@ -3,7 +3,7 @@ package lineapi
 import (
 	"log"
 	"net/http"
-	"strings"
+	"net/url"
 
 	"github.com/mshrtsr/mail-notice-linebot/helper"
 
@@ -57,43 +57,33 @@ func WebhookHandler(w http.ResponseWriter, r *http.Request) {
 		case linebot.EventTypeMessage:
 			switch message := event.Message.(type) {
 			case *linebot.TextMessage:
+				// Text in a multi-step flow such as setup or reply
+				if HandleConversationText(bot, replyToken, targetID, message.Text) {
+					continue
+				}
+				// Commands such as "/help" and their aliases
+				if HandleCommand(bot, replyToken, targetID, message.Text) {
+					continue
+				}
 				switch {
-				case strings.Contains(message.Text, "メールお知らせ"):
-					fallthrough
-				case strings.Contains(message.Text, "メールおしらせ"):
-					SendConfirmSetupForwarding(bot, replyToken, targetID)
-				case strings.Contains(message.Text, "お知らせ解除"):
-					SendConfirmRevokeForwarding(bot, replyToken, targetID)
-				case strings.HasPrefix(message.Text, "VC-"):
-					address, err := VerifyAddress(targetID, message.Text)
-					var contentText string
-					if err != nil {
-						contentText = err.Error()
-					} else {
-						contentText = "メールアドレスが確認されました\n" + address + "\n以下のメールアドレス宛にメール転送設定を行うとお知らせが来るようになります\n" + configVars.IMAP.Address
-					}
-					message := linebot.NewTextMessage(contentText)
-					if _, err := bot.ReplyMessage(replyToken, message).Do(); err != nil {
-						log.Print(err)
-					}
-				case strings.Contains(message.Text, "@"):
-					PushAddressToConfigureQueue(bot, replyToken, targetID, message.Text)
-				case message.Text == ".":
-					FinishConfigureAddress(bot, replyToken, targetID)
+				case isVerificationCode(message.Text):
+					ctx := commandContext{Bot: bot, ReplyToken: replyToken, LineID: targetID, Language: userLanguage(targetID)}
+					replyVerification(ctx, message.Text)
 				default:
 					if eventSourceType == linebot.EventSourceTypeUser {
-						SendRandomReply(bot, replyToken)
+						SendRandomReply(bot, replyToken, targetID, userLanguage(targetID))
 					}
 				}
 			}
 		case linebot.EventTypeFollow:
-			// Send Introduction to user
-			SendIntroduction(bot, replyToken)
+			// Send Introduction to user in the language of the LINE profile
+			SendIntroduction(bot, replyToken, DetectUserLanguage(targetID))
+			DefaultRichMenuManager().Link(bot, targetID)
 		case linebot.EventTypeUnfollow:
 			RevokeRegisteredUser(bot, replyToken, targetID)
 		case linebot.EventTypeJoin:
 			// Send Introduction to the group
-			SendIntroduction(bot, replyToken)
+			SendIntroduction(bot, replyToken, defaultLanguage())
 		case linebot.EventTypeLeave:
 			RevokeRegisteredUser(bot, replyToken, targetID)
 		case linebot.EventTypeMemberJoined:
@@ -104,12 +94,27 @@ func WebhookHandler(w http.ResponseWriter, r *http.Request) {
 			// Default send nothing
 		case linebot.EventTypePostback:
 			data := event.Postback.Data
+			query, _ := url.ParseQuery(data)
+			// Buttons of a multi-step flow such as revoke or reply
+			if HandleConversationPostback(bot, replyToken, targetID, query) {
+				continue
+			}
+			// Rich menu areas
+			if HandleCommandPostback(bot, replyToken, targetID, query) {
+				continue
+			}
+			if len(query.Get("read")) > 0 {
+				SendMailBody(bot, replyToken, targetID, query.Get("read"))
+			}
+			if len(query.Get("reply")) > 0 {
+				StartReplyMail(bot, replyToken, targetID, query.Get("reply"))
+			}
+			if len(query.Get("attachment")) > 0 {
+				SendAttachment(bot, replyToken, targetID, query.Get("attachment"), query.Get("part"))
+			}
 			if data == "setup=true" {
 				StartConfigureAddress(bot, replyToken, targetID)
 			}
-			if data == "revoke=true" {
-				RevokeRegisteredUser(bot, replyToken, targetID)
-			}
 			// Do Nothing
 		case linebot.EventTypeBeacon:
 			// Do Nothing
@@ -9,10 +9,33 @@ import (
 // MailObject ..
 type MailObject struct {
 	TargetLineID        string
-	MailFromName        string
-	MailFromAddress     string
+	MailFrom            []MailAddress
+	MailSender          []MailAddress
+	MailReplyTo         []MailAddress
 	MailReceivedAddress string
 	MailSubject         string
+	MessageKey          string
+	MailID              string
+	Attachments         []Attachment
+
+	// RegisteredAddress matched MailReceivedAddress and is shown as below
+	RegisteredAddress string
+	AddressLabel      string
+	AddressEmoji      string
+	AddressColor      string
+}
+
+// MailFromDisplayName returns display names of the senders
+func (m MailObject) MailFromDisplayName() string {
+	return JoinDisplayNames(m.MailFrom)
+}
+
+// AccountName returns the label of the registered address, or the address the mail was sent to
+func (m MailObject) AccountName() string {
+	if len(m.AddressLabel) > 0 {
+		return m.AddressLabel
+	}
+	return m.MailReceivedAddress
 }
 
 // UserMailObject ..
@@ -22,45 +45,79 @@ type UserMailObject struct {
 }
 
 // ConvertMessagesToUserMailObject ..
-func ConvertMessagesToUserMailObject(messages []imap.Message, lineUsers []mongodb.LineUser) []UserMailObject {
-	var userMailObjects []UserMailObject
-	for _, lineUser := range lineUsers {
-		var mailObjects []MailObject
-	MSG_LOOP:
-		for _, msg := range messages {
-			var addresses []*imap.Address
-			for _, addr := range msg.Envelope.To {
-				addresses = append(addresses, addr)
-			}
-			for _, addr := range msg.Envelope.Cc {
-				addresses = append(addresses, addr)
-			}
-			for _, addr := range msg.Envelope.Bcc {
-				addresses = append(addresses, addr)
-			}
+func ConvertMessagesToUserMailObject(messages []imap.Message, index *AddressIndex, mongodbURL string) []UserMailObject {
+	matchOptions := CurrentAddressMatchOptions()
+
+	// Collect mails per LINE user, keeping the order users first appear
+	var lineIDs []string
+	mailObjectsByLineID := make(map[string][]MailObject)
+	for _, msg := range messages {
+		if msg.Envelope == nil {
+			continue
+		}
+		messageKey := MessageKey(msg)
+		mailID := MailID(messageKey, MessageRaw(msg))
+		attachments := ListAttachments(msg)
 
-			for _, registeredAddress := range lineUser.RegisteredAddresses {
-				for _, address := range addresses {
-					fullAddress := address.MailboxName + "@" + address.HostName
-					if registeredAddress == fullAddress {
-						mailFromAddress := msg.Envelope.From[0]
-						mailObject := MailObject{
-							TargetLineID:        lineUser.LineID,
-							MailFromName:        mailFromAddress.MailboxName + "@" + mailFromAddress.HostName,
-							MailFromAddress:     mailFromAddress.PersonalName,
-							MailReceivedAddress: fullAddress,
-							MailSubject:         msg.Envelope.Subject,
-						}
-						mailObjects = append(mailObjects, mailObject)
-						//log.Println(mailObject)
-						continue MSG_LOOP
-					}
+		var addresses []*imap.Address
+		addresses = append(addresses, msg.Envelope.To...)
+		addresses = append(addresses, msg.Envelope.Cc...)
+		addresses = append(addresses, msg.Envelope.Bcc...)
+
+		matched := make(map[string]bool)
+		for _, address := range addresses {
+			fullAddress := address.MailboxName + "@" + address.HostName
+			for _, entry := range index.Lookup(fullAddress, matchOptions, mongodbURL) {
+				if matched[entry.LineID] {
+					continue
+				}
+				matched[entry.LineID] = true
+
+				mailObject := MailObject{
+					TargetLineID:        entry.LineID,
+					MailFrom:            EnvelopeOriginator(msg.Envelope),
+					MailSender:          NewMailAddressList(msg.Envelope.Sender),
+					MailReplyTo:         NewMailAddressList(msg.Envelope.ReplyTo),
+					MailReceivedAddress: fullAddress,
+					RegisteredAddress:   entry.RegisteredAddress,
+					AddressLabel:        entry.Label,
+					AddressEmoji:        entry.Emoji,
+					AddressColor:        entry.Color,
+					MailSubject:         msg.Envelope.Subject,
+					MessageKey:          messageKey,
+					MailID:              mailID,
+					Attachments:         attachments,
+				}
+				if _, ok := mailObjectsByLineID[entry.LineID]; !ok {
+					lineIDs = append(lineIDs, entry.LineID)
 				}
+				mailObjectsByLineID[entry.LineID] = append(mailObjectsByLineID[entry.LineID], mailObject)
 			}
 		}
+	}
+
+	var userMailObjects []UserMailObject
+	for _, lineID := range lineIDs {
+		candidates := mailObjectsByLineID[lineID]
+		messageKeys := make([]string, len(candidates))
+		for i, mailObject := range candidates {
+			messageKeys[i] = mailObject.MessageKey
+		}
+
+		// Skip messages notified in previous cycles or seen twice in this batch
+		notified := mongodb.ReadNotifiedMessageKeys(lineID, messageKeys, mongodbURL)
+		var mailObjects []MailObject
+		for _, mailObject := range candidates {
+			if notified[mailObject.MessageKey] {
+				continue
+			}
+			notified[mailObject.MessageKey] = true
+			mailObjects = append(mailObjects, mailObject)
+		}
+
 		if len(mailObjects) > 0 {
 			userMailObject := UserMailObject{
-				TargetLineID: lineUser.LineID,
+				TargetLineID: lineID,
 				MailObjects:  mailObjects,
 			}
 			userMailObjects = append(userMailObjects, userMailObject)
@@ -0,0 +1,78 @@
+package mailmanager
+
+import (
+	"strings"
+
+	"github.com/emersion/go-imap"
+)
+
+// MailAddress is a parsed mail address with a decoded display name
+type MailAddress struct {
+	Name    string
+	Address string
+}
+
+// DisplayName returns the display name, or the address when there is none
+func (a MailAddress) DisplayName() string {
+	if len(a.Name) > 0 {
+		return a.Name
+	}
+	return a.Address
+}
+
+// String formats the address as "Name <address>"
+func (a MailAddress) String() string {
+	if len(a.Name) > 0 && len(a.Address) > 0 {
+		return a.Name + " <" + a.Address + ">"
+	}
+	return a.DisplayName()
+}
+
+// NewMailAddress converts an imap.Address. ok is false for group markers and empty addresses.
+func NewMailAddress(addr *imap.Address) (MailAddress, bool) {
+	if addr == nil {
+		return MailAddress{}, false
+	}
+	// RFC 3501 group syntax: start has no host name, end has no mailbox name
+	if len(addr.MailboxName) == 0 || len(addr.HostName) == 0 {
+		return MailAddress{}, false
+	}
+	return MailAddress{
+		Name:    DecodeHeader(strings.Trim(strings.TrimSpace(addr.PersonalName), `"`)),
+		Address: addr.MailboxName + "@" + addr.HostName,
+	}, true
+}
+
+// NewMailAddressList converts an imap.Address list, skipping unusable entries
+func NewMailAddressList(list []*imap.Address) []MailAddress {
+	var addresses []MailAddress
+	for _, addr := range list {
+		if address, ok := NewMailAddress(addr); ok {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// JoinDisplayNames joins display names of addresses with ", "
+func JoinDisplayNames(addresses []MailAddress) string {
+	names := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		names = append(names, address.DisplayName())
+	}
+	return strings.Join(names, ", ")
+}
+
+// EnvelopeOriginator returns the author addresses of a message.
+// From is used first, then Sender and Reply-To for messages with an empty From.
+func EnvelopeOriginator(envelope *imap.Envelope) []MailAddress {
+	if envelope == nil {
+		return nil
+	}
+	for _, list := range [][]*imap.Address{envelope.From, envelope.Sender, envelope.ReplyTo} {
+		if addresses := NewMailAddressList(list); len(addresses) > 0 {
+			return addresses
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package mailmanager
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+)
+
+// addressIndexMaxAge bounds staleness when LineUsers are changed by another process
+const addressIndexMaxAge = 10 * time.Minute
+
+// AddressIndexEntry is a LINE user who registered an address
+type AddressIndexEntry struct {
+	LineID            string
+	RegisteredAddress string
+	Label             string
+	Emoji             string
+	Color             string
+	// MutedUntil skips the entry until the time
+	MutedUntil time.Time
+}
+
+// AddressIndex maps normalized registered addresses to LINE users.
+// It is cached between mail check cycles and rebuilt when LineUsers change.
+type AddressIndex struct {
+	mu       sync.RWMutex
+	entries  map[string][]AddressIndexEntry
+	options  AddressMatchOptions
+	revision int64
+	builtAt  time.Time
+}
+
+var defaultAddressIndex = &AddressIndex{}
+
+// DefaultAddressIndex returns the process wide AddressIndex
+func DefaultAddressIndex() *AddressIndex {
+	return defaultAddressIndex
+}
+
+// Invalidate forces the index to be rebuilt on the next lookup
+func (idx *AddressIndex) Invalidate() {
+	idx.mu.Lock()
+	idx.entries = nil
+	idx.mu.Unlock()
+}
+
+// Lookup returns LINE users who registered an address matching recipient,
+// except those who muted the address
+func (idx *AddressIndex) Lookup(recipient string, opts AddressMatchOptions, mongodbURL string) []AddressIndexEntry {
+	idx.refresh(opts, mongodbURL)
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	now := time.Now()
+	var entries []AddressIndexEntry
+	for _, candidate := range RegisteredAddressCandidates(recipient, opts) {
+		for _, entry := range idx.entries[candidate] {
+			if now.Before(entry.MutedUntil) {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// refresh rebuilds the index when it is stale
+func (idx *AddressIndex) refresh(opts AddressMatchOptions, mongodbURL string) {
+	revision := mongodb.LineUserRevision()
+
+	idx.mu.RLock()
+	fresh := idx.entries != nil && idx.revision == revision && idx.options == opts && time.Since(idx.builtAt) < addressIndexMaxAge
+	idx.mu.RUnlock()
+	if fresh {
+		return
+	}
+
+	entries := make(map[string][]AddressIndexEntry)
+	for _, lineUser := range mongodb.ReadAllLineUsers(mongodbURL) {
+		// Muted users receive no notifications
+		if lineUser.Muted {
+			continue
+		}
+		for _, registeredAddress := range lineUser.RegisteredAddresses {
+			// Muted until resumed. Snoozed addresses are skipped on lookup
+			// because they are unmuted without a LineUser write.
+			if registeredAddress.Muted {
+				continue
+			}
+			key := NormalizeAddress(registeredAddress.Address, opts)
+			entries[key] = append(entries[key], AddressIndexEntry{
+				LineID:            lineUser.LineID,
+				RegisteredAddress: registeredAddress.Address,
+				Label:             registeredAddress.Label,
+				Emoji:             registeredAddress.Emoji,
+				Color:             registeredAddress.Color,
+				MutedUntil:        registeredAddress.MutedUntil,
+			})
+		}
+	}
+
+	idx.mu.Lock()
+	idx.entries = entries
+	idx.options = opts
+	idx.revision = revision
+	idx.builtAt = time.Now()
+	idx.mu.Unlock()
+}
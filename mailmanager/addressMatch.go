@@ -0,0 +1,127 @@
+package mailmanager
+
+import (
+	"strings"
+
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+)
+
+const (
+	subAddressSeparator = "+"
+	wildcardLocalPart   = "*"
+)
+
+// gmailDomains are domains whose local parts ignore dots
+var gmailDomains = map[string]bool{
+	"gmail.com":      true,
+	"googlemail.com": true,
+}
+
+// AddressMatchOptions controls how addresses are normalized and compared
+type AddressMatchOptions struct {
+	// FoldLocalPart compares local parts case-insensitively. Domains are always folded.
+	FoldLocalPart bool
+	// StripSubAddress lets "taro+tag@example.com" match a registered "taro@example.com"
+	StripSubAddress bool
+	// GmailDots ignores dots in local parts of Gmail addresses
+	GmailDots bool
+}
+
+// CurrentAddressMatchOptions returns the options set by environment variables
+func CurrentAddressMatchOptions() AddressMatchOptions {
+	configVars := helper.ConfigVars()
+	return AddressMatchOptions{
+		FoldLocalPart:   configVars.AddressMatch.CaseSensitiveLocalPart != "true",
+		StripSubAddress: configVars.AddressMatch.KeepSubAddress != "true",
+		GmailDots:       configVars.AddressMatch.KeepGmailDots != "true",
+	}
+}
+
+// splitAddress splits an address at the last "@"
+func splitAddress(address string) (string, string) {
+	i := strings.LastIndex(address, "@")
+	if i < 0 {
+		return address, ""
+	}
+	return address[:i], address[i+1:]
+}
+
+// NormalizeAddress returns the canonical form used to store and compare addresses
+func NormalizeAddress(address string, opts AddressMatchOptions) string {
+	address = strings.TrimSpace(address)
+	address = strings.TrimSuffix(strings.TrimPrefix(address, "<"), ">")
+	localPart, domain := splitAddress(address)
+	if len(domain) == 0 {
+		return address
+	}
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+
+	if opts.FoldLocalPart {
+		localPart = strings.ToLower(localPart)
+	}
+	if opts.GmailDots && gmailDomains[domain] {
+		localPart = strings.Replace(localPart, ".", "", -1)
+		domain = "gmail.com"
+	}
+	return localPart + "@" + domain
+}
+
+// IsWildcardAddress reports whether address is a "*@domain" wildcard
+func IsWildcardAddress(address string) bool {
+	localPart, domain := splitAddress(address)
+	return localPart == wildcardLocalPart && len(domain) > 0
+}
+
+// AddressDomain returns the lower-cased domain of address
+func AddressDomain(address string) string {
+	_, domain := splitAddress(address)
+	return strings.ToLower(domain)
+}
+
+// stripSubAddress removes "+tag" from the local part
+func stripSubAddress(address string) string {
+	localPart, domain := splitAddress(address)
+	if len(domain) == 0 {
+		return address
+	}
+	if i := strings.Index(localPart, subAddressSeparator); i > 0 {
+		localPart = localPart[:i]
+	}
+	return localPart + "@" + domain
+}
+
+// RegisteredAddressCandidates returns every registered form that matches recipient:
+// the normalized address, the address without sub-address and the domain wildcard.
+func RegisteredAddressCandidates(recipient string, opts AddressMatchOptions) []string {
+	normalized := NormalizeAddress(recipient, opts)
+	candidates := []string{normalized}
+	if opts.StripSubAddress {
+		if stripped := stripSubAddress(normalized); stripped != normalized {
+			candidates = append(candidates, stripped)
+		}
+	}
+	if domain := AddressDomain(normalized); len(domain) > 0 {
+		candidates = append(candidates, wildcardLocalPart+"@"+domain)
+	}
+	return candidates
+}
+
+// MatchAddress reports whether a mail to recipient should be notified for registered
+func MatchAddress(registered, recipient string, opts AddressMatchOptions) bool {
+	registered = NormalizeAddress(registered, opts)
+	for _, candidate := range RegisteredAddressCandidates(recipient, opts) {
+		if candidate == registered {
+			return true
+		}
+	}
+	return false
+}
+
+// VerificationRecipient returns where the verification mail for address is sent.
+// Domain wildcards are verified through the postmaster of the domain.
+func VerificationRecipient(address string) string {
+	if IsWildcardAddress(address) {
+		return "postmaster@" + AddressDomain(address)
+	}
+	return address
+}
@@ -0,0 +1,190 @@
+package mailmanager
+
+import (
+	"reflect"
+	"testing"
+)
+
+var (
+	defaultMatchOptions = AddressMatchOptions{FoldLocalPart: true, StripSubAddress: true, GmailDots: true}
+	strictMatchOptions  = AddressMatchOptions{}
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		opts    AddressMatchOptions
+		want    string
+	}{
+		{
+			name:    "domain is always folded",
+			address: "Taro@Example.COM",
+			opts:    strictMatchOptions,
+			want:    "Taro@example.com",
+		},
+		{
+			name:    "local part folded",
+			address: "Taro@Example.COM",
+			opts:    defaultMatchOptions,
+			want:    "taro@example.com",
+		},
+		{
+			name:    "angle brackets, spaces and trailing dot",
+			address: " <taro@example.com.> ",
+			opts:    defaultMatchOptions,
+			want:    "taro@example.com",
+		},
+		{
+			name:    "Gmail dots removed",
+			address: "Ta.Ro@gmail.com",
+			opts:    defaultMatchOptions,
+			want:    "taro@gmail.com",
+		},
+		{
+			name:    "googlemail.com is gmail.com",
+			address: "ta.ro@googlemail.com",
+			opts:    defaultMatchOptions,
+			want:    "taro@gmail.com",
+		},
+		{
+			name:    "Gmail dots kept",
+			address: "ta.ro@gmail.com",
+			opts:    AddressMatchOptions{FoldLocalPart: true},
+			want:    "ta.ro@gmail.com",
+		},
+		{
+			name:    "dots kept outside Gmail",
+			address: "ta.ro@example.com",
+			opts:    defaultMatchOptions,
+			want:    "ta.ro@example.com",
+		},
+		{
+			name:    "sub-address is kept in the normalized form",
+			address: "taro+news@example.com",
+			opts:    defaultMatchOptions,
+			want:    "taro+news@example.com",
+		},
+		{
+			name:    "wildcard",
+			address: "*@Example.com",
+			opts:    defaultMatchOptions,
+			want:    "*@example.com",
+		},
+		{
+			name:    "no domain",
+			address: "postmaster",
+			opts:    defaultMatchOptions,
+			want:    "postmaster",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeAddress(tt.address, tt.opts); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisteredAddressCandidates(t *testing.T) {
+	tests := []struct {
+		name      string
+		recipient string
+		opts      AddressMatchOptions
+		want      []string
+	}{
+		{
+			name:      "plain address",
+			recipient: "Taro@Example.com",
+			opts:      defaultMatchOptions,
+			want:      []string{"taro@example.com", "*@example.com"},
+		},
+		{
+			name:      "sub-address stripped",
+			recipient: "taro+news@example.com",
+			opts:      defaultMatchOptions,
+			want:      []string{"taro+news@example.com", "taro@example.com", "*@example.com"},
+		},
+		{
+			name:      "sub-address kept",
+			recipient: "taro+news@example.com",
+			opts:      AddressMatchOptions{FoldLocalPart: true},
+			want:      []string{"taro+news@example.com", "*@example.com"},
+		},
+		{
+			name:      "leading plus is not a sub-address",
+			recipient: "+news@example.com",
+			opts:      defaultMatchOptions,
+			want:      []string{"+news@example.com", "*@example.com"},
+		},
+		{
+			name:      "Gmail with dots and sub-address",
+			recipient: "Ta.Ro+x@googlemail.com",
+			opts:      defaultMatchOptions,
+			want:      []string{"taro+x@gmail.com", "taro@gmail.com", "*@gmail.com"},
+		},
+		{
+			name:      "no domain",
+			recipient: "postmaster",
+			opts:      defaultMatchOptions,
+			want:      []string{"postmaster"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RegisteredAddressCandidates(tt.recipient, tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchAddress(t *testing.T) {
+	tests := []struct {
+		name       string
+		registered string
+		recipient  string
+		opts       AddressMatchOptions
+		want       bool
+	}{
+		{name: "same address", registered: "taro@example.com", recipient: "taro@example.com", opts: defaultMatchOptions, want: true},
+		{name: "case folded", registered: "Taro@Example.com", recipient: "TARO@example.COM", opts: defaultMatchOptions, want: true},
+		{name: "case sensitive local part", registered: "Taro@example.com", recipient: "taro@example.com", opts: strictMatchOptions, want: false},
+		{name: "case sensitive local part, folded domain", registered: "Taro@example.com", recipient: "Taro@EXAMPLE.com", opts: strictMatchOptions, want: true},
+		{name: "plus-tag matches the base address", registered: "taro@example.com", recipient: "taro+news@example.com", opts: defaultMatchOptions, want: true},
+		{name: "plus-tag kept", registered: "taro@example.com", recipient: "taro+news@example.com", opts: strictMatchOptions, want: false},
+		{name: "registered plus-tag matches only itself", registered: "taro+news@example.com", recipient: "taro@example.com", opts: defaultMatchOptions, want: false},
+		{name: "Gmail dots", registered: "taro@gmail.com", recipient: "t.a.r.o@gmail.com", opts: defaultMatchOptions, want: true},
+		{name: "Gmail dots kept", registered: "taro@gmail.com", recipient: "t.a.r.o@gmail.com", opts: strictMatchOptions, want: false},
+		{name: "wildcard", registered: "*@example.com", recipient: "anyone+tag@Example.com", opts: defaultMatchOptions, want: true},
+		{name: "wildcard of another domain", registered: "*@example.com", recipient: "anyone@example.org", opts: defaultMatchOptions, want: false},
+		{name: "wildcard does not cover subdomains", registered: "*@example.com", recipient: "anyone@mail.example.com", opts: defaultMatchOptions, want: false},
+		{name: "another user", registered: "taro@example.com", recipient: "jiro@example.com", opts: defaultMatchOptions, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchAddress(tt.registered, tt.recipient, tt.opts); got != tt.want {
+				t.Errorf("MatchAddress(%q, %q) = %v, want %v", tt.registered, tt.recipient, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerificationRecipient(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{address: "taro@example.com", want: "taro@example.com"},
+		{address: "*@Example.com", want: "postmaster@example.com"},
+		{address: "*", want: "*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			if got := VerificationRecipient(tt.address); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package mailmanager
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/emersion/go-imap"
+)
+
+func TestNewMailAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		addr *imap.Address
+		want MailAddress
+		ok   bool
+	}{
+		{
+			name: "with display name",
+			addr: &imap.Address{PersonalName: "Alice", MailboxName: "alice", HostName: "example.com"},
+			want: MailAddress{Name: "Alice", Address: "alice@example.com"},
+			ok:   true,
+		},
+		{
+			name: "without display name",
+			addr: &imap.Address{MailboxName: "bob", HostName: "example.com"},
+			want: MailAddress{Address: "bob@example.com"},
+			ok:   true,
+		},
+		{
+			name: "quoted display name",
+			addr: &imap.Address{PersonalName: `"Carol Smith"`, MailboxName: "carol", HostName: "example.com"},
+			want: MailAddress{Name: "Carol Smith", Address: "carol@example.com"},
+			ok:   true,
+		},
+		{
+			name: "RFC 2047 B encoded display name",
+			addr: &imap.Address{PersonalName: "=?UTF-8?B?5bGx55Sw5aSq6YOO?=", MailboxName: "taro", HostName: "example.jp"},
+			want: MailAddress{Name: "山田太郎", Address: "taro@example.jp"},
+			ok:   true,
+		},
+		{
+			name: "RFC 2047 Q encoded display name",
+			addr: &imap.Address{PersonalName: "=?ISO-8859-1?Q?Andr=E9?=", MailboxName: "andre", HostName: "example.com"},
+			want: MailAddress{Name: "André", Address: "andre@example.com"},
+			ok:   true,
+		},
+		{
+			name: "group start",
+			addr: &imap.Address{MailboxName: "undisclosed-recipients"},
+			ok:   false,
+		},
+		{
+			name: "group end",
+			addr: &imap.Address{},
+			ok:   false,
+		},
+		{
+			name: "nil",
+			addr: nil,
+			ok:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := NewMailAddress(tt.addr)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewMailAddressList(t *testing.T) {
+	tests := []struct {
+		name string
+		list []*imap.Address
+		want []MailAddress
+	}{
+		{
+			name: "group syntax",
+			list: []*imap.Address{
+				{MailboxName: "team"},
+				{PersonalName: "Alice", MailboxName: "alice", HostName: "example.com"},
+				{MailboxName: "bob", HostName: "example.com"},
+				{},
+			},
+			want: []MailAddress{
+				{Name: "Alice", Address: "alice@example.com"},
+				{Address: "bob@example.com"},
+			},
+		},
+		{
+			name: "empty group",
+			list: []*imap.Address{{MailboxName: "undisclosed-recipients"}, {}},
+			want: nil,
+		},
+		{
+			name: "missing",
+			list: nil,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewMailAddressList(tt.list); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvelopeOriginator(t *testing.T) {
+	alice := &imap.Address{PersonalName: "Alice", MailboxName: "alice", HostName: "example.com"}
+	sender := &imap.Address{PersonalName: "List", MailboxName: "list", HostName: "example.com"}
+	replyTo := &imap.Address{MailboxName: "reply", HostName: "example.com"}
+	groupStart := &imap.Address{MailboxName: "undisclosed-recipients"}
+	groupEnd := &imap.Address{}
+
+	tests := []struct {
+		name     string
+		envelope *imap.Envelope
+		want     []MailAddress
+	}{
+		{
+			name:     "From",
+			envelope: &imap.Envelope{From: []*imap.Address{alice}, Sender: []*imap.Address{sender}},
+			want:     []MailAddress{{Name: "Alice", Address: "alice@example.com"}},
+		},
+		{
+			name:     "empty From falls back to Sender",
+			envelope: &imap.Envelope{Sender: []*imap.Address{sender}, ReplyTo: []*imap.Address{replyTo}},
+			want:     []MailAddress{{Name: "List", Address: "list@example.com"}},
+		},
+		{
+			name:     "group only From falls back to Sender",
+			envelope: &imap.Envelope{From: []*imap.Address{groupStart, groupEnd}, Sender: []*imap.Address{sender}},
+			want:     []MailAddress{{Name: "List", Address: "list@example.com"}},
+		},
+		{
+			name:     "Reply-To is the last resort",
+			envelope: &imap.Envelope{ReplyTo: []*imap.Address{replyTo}},
+			want:     []MailAddress{{Address: "reply@example.com"}},
+		},
+		{
+			name:     "no originator",
+			envelope: &imap.Envelope{},
+			want:     nil,
+		},
+		{
+			name:     "nil envelope",
+			envelope: nil,
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EnvelopeOriginator(tt.envelope); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinDisplayNames(t *testing.T) {
+	tests := []struct {
+		name      string
+		addresses []MailAddress
+		want      string
+	}{
+		{
+			name:      "empty",
+			addresses: nil,
+			want:      "",
+		},
+		{
+			name:      "single name",
+			addresses: []MailAddress{{Name: "Alice", Address: "alice@example.com"}},
+			want:      "Alice",
+		},
+		{
+			name: "mixed names and bare addresses",
+			addresses: []MailAddress{
+				{Name: "Alice", Address: "alice@example.com"},
+				{Address: "bob@example.com"},
+				{Name: "山田太郎", Address: "taro@example.jp"},
+			},
+			want: "Alice, bob@example.com, 山田太郎",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := JoinDisplayNames(tt.addresses); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
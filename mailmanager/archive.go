@@ -0,0 +1,50 @@
+package mailmanager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+)
+
+// MailID derives a short stable identifier from a message key and the raw
+// message. The Message-ID is chosen by the sender, so different mails sharing
+// one must not share an archive readable by each other's recipients.
+func MailID(messageKey string, raw []byte) string {
+	rawSum := sha256.Sum256(raw)
+	sum := sha256.Sum256(append([]byte(messageKey+"\n"), rawSum[:]...))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ArchiveMessages stores notified messages so that users can act on them later.
+// Messages larger than maxBytes are archived without their content.
+func ArchiveMessages(messages []imap.Message, userMailObjects []UserMailObject, maxBytes int64, mongodbURL string) {
+	lineIDsByMailID := make(map[string][]string)
+	for _, userMailObject := range userMailObjects {
+		for _, mailObject := range userMailObject.MailObjects {
+			lineIDsByMailID[mailObject.MailID] = append(lineIDsByMailID[mailObject.MailID], userMailObject.TargetLineID)
+		}
+	}
+
+	for _, msg := range messages {
+		raw := MessageRaw(msg)
+		mailID := MailID(MessageKey(msg), raw)
+		lineIDs, ok := lineIDsByMailID[mailID]
+		if !ok {
+			continue
+		}
+		if int64(len(raw)) > maxBytes {
+			log.Println("ArchiveMessages: too large to archive: ", mailID)
+			raw = nil
+		}
+		mongodb.CreateOrUpdateMailArchive(mongodb.MailArchive{
+			MailID:    mailID,
+			LineIDs:   lineIDs,
+			Raw:       raw,
+			CreatedAt: time.Now(),
+		}, mongodbURL)
+	}
+}
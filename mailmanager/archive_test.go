@@ -0,0 +1,18 @@
+package mailmanager
+
+import "testing"
+
+func TestMailID(t *testing.T) {
+	const messageKey = "mid:1@example.com"
+	raw := []byte("Subject: to Alice\r\n\r\nsecret\r\n")
+
+	if MailID(messageKey, raw) != MailID(messageKey, append([]byte(nil), raw...)) {
+		t.Error("the same mail gets different MailIDs")
+	}
+	if MailID(messageKey, raw) == MailID(messageKey, []byte("Subject: to Bob\r\n\r\nhello\r\n")) {
+		t.Error("different mails with one Message-ID share a MailID")
+	}
+	if MailID(messageKey, raw) == MailID("mid:2@example.com", raw) {
+		t.Error("different Message-IDs share a MailID")
+	}
+}
@@ -0,0 +1,142 @@
+package mailmanager
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-imap"
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+)
+
+// DefaultAttachmentMaxBytes is the LINE limit of original image content
+const DefaultAttachmentMaxBytes = 10 << 20
+
+// AttachmentMaxBytes returns the size limit of attachments forwarded to LINE
+func AttachmentMaxBytes() int64 {
+	configVars := helper.ConfigVars()
+	return helper.Int64OrDefault(configVars.Attachment.MaxBytes, DefaultAttachmentMaxBytes)
+}
+
+// Attachment describes a file attached to a mail
+type Attachment struct {
+	Name     string
+	MIMEType string
+	Size     int64
+	// Part is the IMAP section number of the attachment, e.g. "2" or "1.3"
+	Part string
+}
+
+// ForwardableAttachmentTypes are attachment types which can be sent to LINE
+var ForwardableAttachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"application/pdf": true,
+}
+
+// IsImage reports whether the attachment is sent as a LINE image message
+func (a Attachment) IsImage() bool {
+	return strings.HasPrefix(a.MIMEType, "image/")
+}
+
+// Forwardable reports whether the attachment can be sent to LINE within maxBytes
+func (a Attachment) Forwardable(maxBytes int64) bool {
+	return ForwardableAttachmentTypes[a.MIMEType] && a.Size <= maxBytes
+}
+
+// FormatSize formats the attachment size in B/KB/MB
+func (a Attachment) FormatSize() string {
+	switch {
+	case a.Size >= 1<<20:
+		return strconv.FormatFloat(float64(a.Size)/(1<<20), 'f', 1, 64) + "MB"
+	case a.Size >= 1<<10:
+		return strconv.FormatInt(a.Size>>10, 10) + "KB"
+	default:
+		return strconv.FormatInt(a.Size, 10) + "B"
+	}
+}
+
+// ListAttachments lists attachments from BODYSTRUCTURE, or from the raw message
+// for sources which do not provide one
+func ListAttachments(msg imap.Message) []Attachment {
+	if msg.BodyStructure != nil {
+		var attachments []Attachment
+		walkBodyStructure(msg.BodyStructure, "", &attachments)
+		return attachments
+	}
+
+	raw := MessageRaw(msg)
+	if raw == nil {
+		return nil
+	}
+	root, err := parseMIME(raw)
+	if err != nil {
+		return nil
+	}
+	var attachments []Attachment
+	root.walk(func(part *mimePart) {
+		if part.isAttachment() {
+			attachments = append(attachments, Attachment{
+				Name:     part.fileName(),
+				MIMEType: part.MediaType,
+				Size:     int64(len(part.Body)),
+				Part:     part.Path,
+			})
+		}
+	})
+	return attachments
+}
+
+func walkBodyStructure(bs *imap.BodyStructure, path string, attachments *[]Attachment) {
+	if strings.EqualFold(bs.MIMEType, "multipart") {
+		for i, part := range bs.Parts {
+			walkBodyStructure(part, childPath(path, i+1), attachments)
+		}
+		return
+	}
+	if len(path) == 0 {
+		path = "1"
+	}
+
+	name := DecodeHeader(bs.DispositionParams["filename"])
+	if len(name) == 0 {
+		name = DecodeHeader(bs.Params["name"])
+	}
+	mimeType := strings.ToLower(bs.MIMEType + "/" + bs.MIMESubType)
+	isAttachment := strings.EqualFold(bs.Disposition, "attachment") ||
+		(len(name) > 0 && !strings.EqualFold(bs.MIMEType, "text"))
+	if !isAttachment {
+		return
+	}
+
+	// BODYSTRUCTURE reports the encoded size
+	size := int64(bs.Size)
+	if strings.EqualFold(bs.Encoding, "base64") {
+		size = size * 3 / 4
+	}
+	*attachments = append(*attachments, Attachment{
+		Name:     name,
+		MIMEType: mimeType,
+		Size:     size,
+		Part:     path,
+	})
+}
+
+// ReadAttachment decodes the attachment at part from a raw message
+func ReadAttachment(raw []byte, part string) ([]byte, Attachment, error) {
+	root, err := parseMIME(raw)
+	if err != nil {
+		return nil, Attachment{}, err
+	}
+	p := root.find(part)
+	if p == nil || !p.isAttachment() {
+		return nil, Attachment{}, errors.New("attachment not found")
+	}
+	attachment := Attachment{
+		Name:     p.fileName(),
+		MIMEType: p.MediaType,
+		Size:     int64(len(p.Body)),
+		Part:     p.Path,
+	}
+	return p.Body, attachment, nil
+}
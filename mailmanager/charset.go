@@ -0,0 +1,32 @@
+package mailmanager
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/emersion/go-imap"
+	"golang.org/x/text/encoding/japanese"
+)
+
+func init() {
+	// Let go-imap decode Japanese headers in envelopes as well
+	if imap.CharsetReader == nil {
+		imap.CharsetReader = CharsetReader
+	}
+}
+
+// CharsetReader converts text in charset to UTF-8
+func CharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return input, nil
+	case "iso-2022-jp", "csiso2022jp":
+		return japanese.ISO2022JP.NewDecoder().Reader(input), nil
+	case "shift_jis", "shift-jis", "sjis", "x-sjis", "cp932", "windows-31j", "ms_kanji":
+		return japanese.ShiftJIS.NewDecoder().Reader(input), nil
+	case "euc-jp", "x-euc-jp", "eucjp":
+		return japanese.EUCJP.NewDecoder().Reader(input), nil
+	}
+	return nil, fmt.Errorf("unhandled charset %q", charset)
+}
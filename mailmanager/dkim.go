@@ -0,0 +1,229 @@
+package mailmanager
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+)
+
+// dkimSignedHeaders are signed when present in the message
+var dkimSignedHeaders = []string{
+	"From", "Sender", "Reply-To", "To", "Cc", "Subject", "Date", "Message-ID",
+	"In-Reply-To", "References", "MIME-Version", "Content-Type", "Content-Transfer-Encoding",
+}
+
+// DKIMSigner signs outgoing messages with relaxed/relaxed canonicalization
+type DKIMSigner struct {
+	Domain   string
+	Selector string
+	// Key is a *rsa.PrivateKey (rsa-sha256) or ed25519.PrivateKey (ed25519-sha256)
+	Key crypto.Signer
+}
+
+// CurrentDKIMSigner returns the signer set by environment variables,
+// or nil when DKIM is not configured
+func CurrentDKIMSigner() *DKIMSigner {
+	configVars := helper.ConfigVars()
+	if len(configVars.DKIM.Selector) == 0 || len(configVars.DKIM.PrivateKey) == 0 {
+		return nil
+	}
+	domain := configVars.DKIM.Domain
+	if len(domain) == 0 {
+		domain = AddressDomain(configVars.SMTP.SenderAddress)
+	}
+
+	signer, err := NewDKIMSigner(domain, configVars.DKIM.Selector, configVars.DKIM.PrivateKey)
+	if err != nil {
+		log.Print(err)
+		return nil
+	}
+	return signer
+}
+
+// NewDKIMSigner loads a PEM encoded private key. privateKey is either the PEM
+// itself (literal "\n" are accepted for environment variables) or a file path.
+func NewDKIMSigner(domain, selector, privateKey string) (*DKIMSigner, error) {
+	if len(domain) == 0 {
+		return nil, errors.New("dkim: domain is not set")
+	}
+
+	data := []byte(strings.Replace(privateKey, `\n`, "\n", -1))
+	if !strings.HasPrefix(strings.TrimSpace(string(data)), "-----BEGIN") {
+		var err error
+		if data, err = ioutil.ReadFile(privateKey); err != nil {
+			return nil, err
+		}
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("dkim: no PEM private key found")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return &DKIMSigner{Domain: domain, Selector: selector, Key: key}, nil
+	case ed25519.PrivateKey:
+		return &DKIMSigner{Domain: domain, Selector: selector, Key: key}, nil
+	}
+	return nil, errors.New("dkim: unsupported private key type")
+}
+
+func (s *DKIMSigner) algorithm() string {
+	if _, ok := s.Key.(ed25519.PrivateKey); ok {
+		return "ed25519-sha256"
+	}
+	return "rsa-sha256"
+}
+
+// Sign returns message with a DKIM-Signature header prepended
+func (s *DKIMSigner) Sign(message []byte) ([]byte, error) {
+	i := bytes.Index(message, []byte("\r\n\r\n"))
+	if i < 0 {
+		return nil, errors.New("dkim: message has no body")
+	}
+	fields := splitHeaderFields(string(message[:i+2]))
+	body := message[i+4:]
+
+	bodyHash := sha256.Sum256(relaxedBody(body))
+
+	// Select headers from the bottom, as verifiers do
+	var names []string
+	var signed []string
+	used := make(map[int]bool)
+	for _, name := range dkimSignedHeaders {
+		for j := len(fields) - 1; j >= 0; j-- {
+			if used[j] || !strings.EqualFold(fieldName(fields[j]), name) {
+				continue
+			}
+			used[j] = true
+			names = append(names, strings.ToLower(name))
+			signed = append(signed, relaxedHeader(fields[j]))
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.New("dkim: no headers to sign")
+	}
+
+	value := "v=1; a=" + s.algorithm() + "; c=relaxed/relaxed; d=" + s.Domain + "; s=" + s.Selector +
+		"; t=" + strconv.FormatInt(time.Now().Unix(), 10) +
+		"; h=" + strings.Join(names, ":") +
+		"; bh=" + base64.StdEncoding.EncodeToString(bodyHash[:]) + "; b="
+
+	// The signature header itself is hashed with an empty b= and no CRLF
+	data := strings.Join(signed, "") + strings.TrimSuffix(relaxedHeader("DKIM-Signature: "+value+"\r\n"), "\r\n")
+	hash := sha256.Sum256([]byte(data))
+
+	var signature []byte
+	var err error
+	switch key := s.Key.(type) {
+	case ed25519.PrivateKey:
+		// RFC 8463: Ed25519 signs the SHA-256 hash
+		signature = ed25519.Sign(key, hash[:])
+	default:
+		signature, err = key.Sign(rand.Reader, hash[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := writeHeader(&buf, "DKIM-Signature", value+base64.StdEncoding.EncodeToString(signature)); err != nil {
+		return nil, err
+	}
+	buf.Write(message)
+	return buf.Bytes(), nil
+}
+
+// splitHeaderFields splits a header block into fields including folded lines and CRLF
+func splitHeaderFields(header string) []string {
+	var fields []string
+	for _, line := range strings.SplitAfter(header, "\r\n") {
+		if len(line) == 0 {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1] += line
+			continue
+		}
+		fields = append(fields, line)
+	}
+	return fields
+}
+
+func fieldName(field string) string {
+	if i := strings.Index(field, ":"); i >= 0 {
+		return strings.TrimSpace(field[:i])
+	}
+	return ""
+}
+
+// relaxedHeader canonicalizes a header field (RFC 6376 3.4.2)
+func relaxedHeader(field string) string {
+	i := strings.Index(field, ":")
+	if i < 0 {
+		return ""
+	}
+	name := strings.ToLower(strings.TrimSpace(field[:i]))
+	value := strings.Replace(field[i+1:], "\r\n", "", -1)
+	value = strings.Join(strings.FieldsFunc(value, isWSP), " ")
+	return name + ":" + value + "\r\n"
+}
+
+// relaxedBody canonicalizes a body (RFC 6376 3.4.4)
+func relaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		line = strings.TrimRightFunc(line, isWSP)
+		var b strings.Builder
+		inWSP := false
+		for _, r := range line {
+			if isWSP(r) {
+				if !inWSP {
+					b.WriteByte(' ')
+				}
+				inWSP = true
+				continue
+			}
+			inWSP = false
+			b.WriteRune(r)
+		}
+		lines[i] = b.String()
+	}
+	for len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func isWSP(r rune) bool {
+	return r == ' ' || r == '\t'
+}
@@ -0,0 +1,271 @@
+package mailmanager
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/mail"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// verifyDKIM checks the first DKIM-Signature of message and returns its tags
+func verifyDKIM(t *testing.T, message []byte, publicKey crypto.PublicKey) map[string]string {
+	t.Helper()
+
+	i := bytes.Index(message, []byte("\r\n\r\n"))
+	if i < 0 {
+		t.Fatal("message has no body")
+	}
+	fields := splitHeaderFields(string(message[:i+2]))
+	body := message[i+4:]
+	if len(fields) == 0 || !strings.EqualFold(fieldName(fields[0]), "DKIM-Signature") {
+		t.Fatalf("first header is not DKIM-Signature: %q", fields[0])
+	}
+	signatureField := fields[0]
+
+	tags := make(map[string]string)
+	unfolded := strings.Replace(signatureField[strings.Index(signatureField, ":")+1:], "\r\n", "", -1)
+	for _, tag := range strings.Split(unfolded, ";") {
+		if kv := strings.SplitN(strings.TrimSpace(tag), "=", 2); len(kv) == 2 {
+			tags[kv[0]] = strings.Join(strings.FieldsFunc(kv[1], isWSP), "")
+		}
+	}
+
+	bodyHash := sha256.Sum256(relaxedBody(body))
+	if got := base64.StdEncoding.EncodeToString(bodyHash[:]); got != tags["bh"] {
+		t.Fatalf("bh = %s, want %s", tags["bh"], got)
+	}
+
+	// Hash the h= fields from the bottom, then the signature with an empty b=
+	var data strings.Builder
+	used := make(map[int]bool)
+	for _, name := range strings.Split(tags["h"], ":") {
+		name = strings.TrimSpace(name)
+		for j := len(fields) - 1; j > 0; j-- {
+			if used[j] || !strings.EqualFold(fieldName(fields[j]), name) {
+				continue
+			}
+			used[j] = true
+			data.WriteString(relaxedHeader(fields[j]))
+			break
+		}
+	}
+	emptyB := regexp.MustCompile(`(b=)[A-Za-z0-9+/=\s]*$`).ReplaceAllString(strings.TrimSuffix(signatureField, "\r\n"), "${1}")
+	data.WriteString(strings.TrimSuffix(relaxedHeader(emptyB+"\r\n"), "\r\n"))
+	hash := sha256.Sum256([]byte(data.String()))
+
+	signature, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature); err != nil {
+			t.Fatalf("rsa signature: %v", err)
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, hash[:], signature) {
+			t.Fatal("ed25519 signature does not verify")
+		}
+	default:
+		t.Fatalf("unsupported key %T", publicKey)
+	}
+	return tags
+}
+
+// rfc8463Message is the ed25519-sha256 example of RFC 8463 Appendix A.3,
+// signed by another implementation with the key of Appendix A.1
+const rfc8463Message = "DKIM-Signature: v=1; a=ed25519-sha256; c=relaxed/relaxed;\r\n" +
+	" d=football.example.com; i=@football.example.com;\r\n" +
+	" q=dns/txt; s=brisbane; t=1528637909; h=from : to :\r\n" +
+	" subject : date : message-id : from : subject : date;\r\n" +
+	" bh=2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8=;\r\n" +
+	" b=/gCrinpcQOoIfuHNQIbq4pgh9kyIK3AQUdt9OdqQehSwhEIug4D11Bus\r\n" +
+	" Fa3bT3FY5OsU7ZbnKELq+eXdp1Q1Dw==\r\n" +
+	"From: Joe SixPack <joe@football.example.com>\r\n" +
+	"To: Suzie Q <suzie@shopping.example.net>\r\n" +
+	"Subject: Is dinner ready?\r\n" +
+	"Date: Fri, 11 Jul 2003 21:00:37 -0700 (PDT)\r\n" +
+	"Message-ID: <20030712040037.46341.5F8J@football.example.com>\r\n" +
+	"\r\n" +
+	"Hi.\r\n" +
+	"\r\n" +
+	"We lost the game.  Are you hungry yet?\r\n" +
+	"\r\n" +
+	"Joe.\r\n"
+
+func TestDKIMRFC8463Vector(t *testing.T) {
+	seed, err := base64.StdEncoding.DecodeString("nWGxne/9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := ed25519.NewKeyFromSeed(seed)
+	publicKey := key.Public().(ed25519.PublicKey)
+	if got := base64.StdEncoding.EncodeToString(publicKey); got != "11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=" {
+		t.Fatalf("public key = %s", got)
+	}
+
+	// The expected bh= and b= come from the RFC, not from this package
+	tags := verifyDKIM(t, []byte(rfc8463Message), publicKey)
+	if tags["bh"] != "2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8=" {
+		t.Errorf("bh = %s", tags["bh"])
+	}
+}
+
+func testOutgoingMessage() *OutgoingMessage {
+	return &OutgoingMessage{
+		From:    mail.Address{Name: "メールお知らせくん", Address: "bot@example.com"},
+		To:      []mail.Address{{Name: "Alice", Address: "alice@example.org"}},
+		Subject: "確認コードのお知らせ with a subject long enough to be folded onto a second header line",
+		Text:    "Verification code: VC-123\r\n\r\n",
+		HTML:    "<p>Verification code: <code>VC-123</code></p>",
+	}
+}
+
+func TestDKIMSignerSign(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		key       crypto.Signer
+		publicKey crypto.PublicKey
+		algorithm string
+	}{
+		{name: "rsa", key: rsaKey, publicKey: &rsaKey.PublicKey, algorithm: "rsa-sha256"},
+		{name: "ed25519", key: edPrivate, publicKey: edPublic, algorithm: "ed25519-sha256"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := &DKIMSigner{Domain: "example.com", Selector: "mail", Key: tt.key}
+			message, err := renderMessage(testOutgoingMessage(), signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tags := verifyDKIM(t, message, tt.publicKey)
+			if tags["a"] != tt.algorithm || tags["d"] != "example.com" || tags["s"] != "mail" || tags["c"] != "relaxed/relaxed" {
+				t.Errorf("unexpected tags %v", tags)
+			}
+			for _, name := range []string{"from", "to", "subject", "date", "message-id"} {
+				if !strings.Contains(":"+tags["h"]+":", ":"+name+":") {
+					t.Errorf("h= %q does not sign %s", tags["h"], name)
+				}
+			}
+
+			// A changed body must not verify
+			tampered := bytes.Replace(message, []byte("VC-123"), []byte("VC-456"), 1)
+			body := tampered[bytes.Index(tampered, []byte("\r\n\r\n"))+4:]
+			bodyHash := sha256.Sum256(relaxedBody(body))
+			if base64.StdEncoding.EncodeToString(bodyHash[:]) == tags["bh"] {
+				t.Error("tampered body has the same hash")
+			}
+		})
+	}
+}
+
+func TestNewDKIMSigner(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(edPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs1PEM := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}))
+	pkcs8PEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}))
+
+	tests := []struct {
+		name      string
+		key       string
+		algorithm string
+	}{
+		{name: "PKCS1 RSA", key: pkcs1PEM, algorithm: "rsa-sha256"},
+		{name: "PKCS8 Ed25519", key: pkcs8PEM, algorithm: "ed25519-sha256"},
+		{name: "escaped newlines", key: strings.Replace(pkcs8PEM, "\n", `\n`, -1), algorithm: "ed25519-sha256"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewDKIMSigner("example.com", "mail", tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := signer.algorithm(); got != tt.algorithm {
+				t.Errorf("algorithm = %s, want %s", got, tt.algorithm)
+			}
+		})
+	}
+}
+
+func TestRelaxedHeader(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		want  string
+	}{
+		{name: "lower-cases the name", field: "SUBJECT: Hello\r\n", want: "subject:Hello\r\n"},
+		{name: "space around the colon", field: "Subject \t:  Hello\r\n", want: "subject:Hello\r\n"},
+		{name: "folded", field: "Subject: Hello\r\n\tthere\r\n  world\r\n", want: "subject:Hello there world\r\n"},
+		{name: "runs of WSP", field: "To: a  \t b\r\n", want: "to:a b\r\n"},
+		{name: "trailing WSP", field: "To: a@example.com \t \r\n", want: "to:a@example.com\r\n"},
+		{name: "empty value", field: "Cc:\r\n", want: "cc:\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := relaxedHeader(tt.field); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRelaxedBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "empty", body: "", want: ""},
+		{name: "only CRLF", body: "\r\n\r\n\r\n", want: ""},
+		{name: "missing final CRLF", body: "Hello", want: "Hello\r\n"},
+		{name: "trailing empty lines", body: "Hello\r\n\r\n\r\n", want: "Hello\r\n"},
+		{name: "trailing WSP", body: "Hello \t\r\nWorld  \r\n", want: "Hello\r\nWorld\r\n"},
+		{name: "runs of WSP", body: "a \t b\r\n", want: "a b\r\n"},
+		{name: "leading WSP kept as one space", body: "  indented\r\n", want: " indented\r\n"},
+		{name: "inner empty lines kept", body: "a\r\n\r\nb\r\n", want: "a\r\n\r\nb\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(relaxedBody([]byte(tt.body))); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitHeaderFields(t *testing.T) {
+	header := "From: a@example.com\r\nSubject: one\r\n two\r\n\tthree\r\nTo: b@example.com\r\n"
+	want := []string{"From: a@example.com\r\n", "Subject: one\r\n two\r\n\tthree\r\n", "To: b@example.com\r\n"}
+	got := splitHeaderFields(header)
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -48,20 +48,8 @@ func FetchMail(timeSince, timeBefore time.Time, mboxName, imapServerName, imapAu
 		seqset := new(imap.SeqSet)
 		seqset.AddNum(ids...)
 
-		messages := make(chan *imap.Message, 10)
-		done := make(chan error, 1)
-		go func() {
-			done <- c.Fetch(seqset, []imap.FetchItem{imap.FetchEnvelope}, messages)
-		}()
-
-		//log.Println(len(ids), "messages:")
-		var messageEntities []imap.Message
-		for msg := range messages {
-			//log.Println(msg.Envelope.Date.String() + ":" + msg.Envelope.Subject)
-			messageEntities = append(messageEntities, *msg)
-		}
-
-		if err := <-done; err != nil {
+		messageEntities, err := fetchMessages(c, seqset)
+		if err != nil {
 			log.Print(err)
 		}
 
@@ -168,22 +156,8 @@ func PopMail(timeSince, timeBefore time.Time, mboxName, imapServerName, imapAuth
 	seqset := new(imap.SeqSet)
 	seqset.AddNum(ids...)
 
-	messages := make(chan *imap.Message, 10)
-	done := make(chan error, 1)
-	go func() {
-		done <- c.Fetch(seqset, []imap.FetchItem{imap.FetchEnvelope}, messages)
-	}()
-
-	//log.Println(len(ids), "messages:")
-	var messageEntities []imap.Message
-	var deleteIds []uint32
-	for msg := range messages {
-		//log.Println(msg.Envelope.Date.String() + ":" + msg.Envelope.Subject)
-		messageEntities = append(messageEntities, *msg)
-		deleteIds = append(deleteIds, msg.Uid)
-	}
-
-	if err := <-done; err != nil {
+	messageEntities, err := fetchMessages(c, seqset)
+	if err != nil {
 		log.Print(err)
 	}
 
@@ -0,0 +1,173 @@
+package mailmanager
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// fetchMessages fetches the envelope and BODYSTRUCTURE of seqset first, then
+// downloads only the sections needed to notify, read and forward each message.
+// The raw message rebuilt from them leaves out the bodies of other parts.
+func fetchMessages(c *client.Client, seqset *imap.SeqSet) ([]imap.Message, error) {
+	messages := make(chan *imap.Message, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Fetch(seqset, []imap.FetchItem{imap.FetchEnvelope, imap.FetchBodyStructure, imap.FetchUid}, messages)
+	}()
+	var messageEntities []imap.Message
+	for msg := range messages {
+		messageEntities = append(messageEntities, *msg)
+	}
+	if err := <-done; err != nil {
+		return messageEntities, err
+	}
+
+	maxBytes := AttachmentMaxBytes()
+	for i := range messageEntities {
+		msg := &messageEntities[i]
+		raw, err := fetchNeededSections(c, msg.Uid, msg.BodyStructure, maxBytes)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		if msg.Body == nil {
+			msg.Body = make(map[*imap.BodySectionName]imap.Literal)
+		}
+		msg.Body[rawSection] = bytes.NewBuffer(raw)
+	}
+	return messageEntities, nil
+}
+
+// fetchNeededSections downloads the sections of a message planned by
+// neededSections and puts them together as a raw message
+func fetchNeededSections(c *client.Client, uid uint32, bs *imap.BodyStructure, maxBytes int64) ([]byte, error) {
+	sections := neededSections(bs, maxBytes)
+	items := make([]imap.FetchItem, 0, len(sections))
+	for _, section := range sections {
+		items = append(items, section.FetchItem())
+	}
+
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uid)
+	messages := make(chan *imap.Message, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.UidFetch(seqset, items, messages)
+	}()
+	var fetched *imap.Message
+	for msg := range messages {
+		fetched = msg
+	}
+	if err := <-done; err != nil {
+		return nil, err
+	}
+	if fetched == nil {
+		return nil, nil
+	}
+
+	contents := make(map[string][]byte, len(sections))
+	for _, section := range sections {
+		literal := fetched.GetBody(section)
+		if literal == nil {
+			continue
+		}
+		content, err := ioutil.ReadAll(literal)
+		if err != nil {
+			return nil, err
+		}
+		contents[sectionKey(section)] = content
+	}
+	return assembleSections(bs, contents), nil
+}
+
+// neededSections returns the sections of a message to download: the whole
+// message when it is not multipart, otherwise the headers of every part and
+// the bodies of text parts and of attachments forwardable within maxBytes
+func neededSections(bs *imap.BodyStructure, maxBytes int64) []*imap.BodySectionName {
+	if bs == nil || !strings.EqualFold(bs.MIMEType, "multipart") {
+		return []*imap.BodySectionName{{Peek: true}}
+	}
+	sections := []*imap.BodySectionName{peekSection(imap.HeaderSpecifier, nil)}
+	walkNeededSections(bs, nil, maxBytes, &sections)
+	return sections
+}
+
+func walkNeededSections(bs *imap.BodyStructure, path []int, maxBytes int64, sections *[]*imap.BodySectionName) {
+	for i, part := range bs.Parts {
+		partPath := append(append([]int(nil), path...), i+1)
+		*sections = append(*sections, peekSection(imap.MIMESpecifier, partPath))
+		if strings.EqualFold(part.MIMEType, "multipart") {
+			walkNeededSections(part, partPath, maxBytes, sections)
+			continue
+		}
+		if partNeeded(part, maxBytes) {
+			*sections = append(*sections, peekSection(imap.EntireSpecifier, partPath))
+		}
+	}
+}
+
+// partNeeded reports whether the body of a part is read by notifications,
+// the mail body reply or forwarded attachments
+func partNeeded(bs *imap.BodyStructure, maxBytes int64) bool {
+	var attachments []Attachment
+	walkBodyStructure(bs, "1", &attachments)
+	if len(attachments) > 0 {
+		return attachments[0].Forwardable(maxBytes)
+	}
+	return strings.EqualFold(bs.MIMEType, "text")
+}
+
+func peekSection(specifier imap.PartSpecifier, path []int) *imap.BodySectionName {
+	return &imap.BodySectionName{
+		BodyPartName: imap.BodyPartName{Specifier: specifier, Path: path},
+		Peek:         true,
+	}
+}
+
+// sectionKey identifies a section, e.g. "BODY.PEEK[1.MIME]"
+func sectionKey(section *imap.BodySectionName) string {
+	return string(section.FetchItem())
+}
+
+// assembleSections rebuilds a raw message from downloaded sections. Parts
+// whose bodies were not downloaded are kept with their headers only.
+func assembleSections(bs *imap.BodyStructure, contents map[string][]byte) []byte {
+	if raw, ok := contents[sectionKey(&imap.BodySectionName{Peek: true})]; ok {
+		return raw
+	}
+	var buf bytes.Buffer
+	buf.Write(contents[sectionKey(peekSection(imap.HeaderSpecifier, nil))])
+	assembleMultipart(&buf, bs, nil, contents)
+	return buf.Bytes()
+}
+
+func assembleMultipart(buf *bytes.Buffer, bs *imap.BodyStructure, path []int, contents map[string][]byte) {
+	boundary := bodyStructureParam(bs, "boundary")
+	for i, part := range bs.Parts {
+		partPath := append(append([]int(nil), path...), i+1)
+		buf.WriteString("--" + boundary + "\r\n")
+		buf.Write(contents[sectionKey(peekSection(imap.MIMESpecifier, partPath))])
+		if strings.EqualFold(part.MIMEType, "multipart") {
+			assembleMultipart(buf, part, partPath, contents)
+		} else {
+			buf.Write(contents[sectionKey(peekSection(imap.EntireSpecifier, partPath))])
+		}
+		buf.WriteString("\r\n")
+	}
+	buf.WriteString("--" + boundary + "--\r\n")
+}
+
+// bodyStructureParam looks up a parameter regardless of the case servers send
+func bodyStructureParam(bs *imap.BodyStructure, name string) string {
+	for key, value := range bs.Params {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
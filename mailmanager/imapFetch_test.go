@@ -0,0 +1,91 @@
+package mailmanager
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-imap"
+)
+
+// testBodyStructure is multipart/mixed with a multipart/alternative text,
+// a forwardable PDF and a zip file that is never forwarded
+func testBodyStructure() *imap.BodyStructure {
+	return &imap.BodyStructure{
+		MIMEType: "multipart", MIMESubType: "mixed", Params: map[string]string{"BOUNDARY": "outer"},
+		Parts: []*imap.BodyStructure{
+			{
+				MIMEType: "multipart", MIMESubType: "alternative", Params: map[string]string{"boundary": "inner"},
+				Parts: []*imap.BodyStructure{
+					{MIMEType: "text", MIMESubType: "plain", Size: 6},
+					{MIMEType: "text", MIMESubType: "html", Size: 13},
+				},
+			},
+			{MIMEType: "application", MIMESubType: "pdf", Disposition: "attachment", DispositionParams: map[string]string{"filename": "a.pdf"}, Encoding: "base64", Size: 8},
+			{MIMEType: "application", MIMESubType: "zip", Disposition: "attachment", DispositionParams: map[string]string{"filename": "b.zip"}, Encoding: "base64", Size: 40 << 20},
+		},
+	}
+}
+
+func TestNeededSections(t *testing.T) {
+	tests := []struct {
+		name string
+		bs   *imap.BodyStructure
+		want string
+	}{
+		{name: "no BODYSTRUCTURE", bs: nil, want: "BODY.PEEK[]"},
+		{name: "single part", bs: &imap.BodyStructure{MIMEType: "text", MIMESubType: "plain"}, want: "BODY.PEEK[]"},
+		{
+			name: "multipart",
+			bs:   testBodyStructure(),
+			want: "BODY.PEEK[HEADER] BODY.PEEK[1.MIME] BODY.PEEK[1.1.MIME] BODY.PEEK[1.1] BODY.PEEK[1.2.MIME] BODY.PEEK[1.2] " +
+				"BODY.PEEK[2.MIME] BODY.PEEK[2] BODY.PEEK[3.MIME]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var keys []string
+			for _, section := range neededSections(tt.bs, DefaultAttachmentMaxBytes) {
+				keys = append(keys, sectionKey(section))
+			}
+			if got := strings.Join(keys, " "); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembleSections(t *testing.T) {
+	section := func(specifier imap.PartSpecifier, path ...int) string {
+		return sectionKey(peekSection(specifier, path))
+	}
+	contents := map[string][]byte{
+		section(imap.HeaderSpecifier):       []byte("From: a@example.com\r\nSubject: Hello\r\nContent-Type: multipart/mixed; boundary=outer\r\n\r\n"),
+		section(imap.MIMESpecifier, 1):      []byte("Content-Type: multipart/alternative; boundary=inner\r\n\r\n"),
+		section(imap.MIMESpecifier, 1, 1):   []byte("Content-Type: text/plain; charset=utf-8\r\n\r\n"),
+		section(imap.EntireSpecifier, 1, 1): []byte("Hello!"),
+		section(imap.MIMESpecifier, 1, 2):   []byte("Content-Type: text/html; charset=utf-8\r\n\r\n"),
+		section(imap.EntireSpecifier, 1, 2): []byte("<p>Hello!</p>"),
+		section(imap.MIMESpecifier, 2):      []byte("Content-Type: application/pdf\r\nContent-Disposition: attachment; filename=a.pdf\r\nContent-Transfer-Encoding: base64\r\n\r\n"),
+		section(imap.EntireSpecifier, 2):    []byte("JVBERi0x"),
+		section(imap.MIMESpecifier, 3):      []byte("Content-Type: application/zip\r\nContent-Disposition: attachment; filename=b.zip\r\nContent-Transfer-Encoding: base64\r\n\r\n"),
+	}
+	raw := assembleSections(testBodyStructure(), contents)
+
+	text, err := ExtractText(raw)
+	if err != nil || text != "Hello!" {
+		t.Errorf("ExtractText = %q, %v", text, err)
+	}
+	content, attachment, err := ReadAttachment(raw, "2")
+	if err != nil || !bytes.Equal(content, []byte("%PDF-1")) || attachment.Name != "a.pdf" {
+		t.Errorf("ReadAttachment(2) = %q, %+v, %v", content, attachment, err)
+	}
+	if content, _, err := ReadAttachment(raw, "3"); err != nil || len(content) != 0 {
+		t.Errorf("ReadAttachment(3) = %q, %v, want the header only", content, err)
+	}
+
+	whole := []byte("Subject: single\r\n\r\nbody")
+	if got := assembleSections(nil, map[string][]byte{sectionKey(&imap.BodySectionName{Peek: true}): whole}); !bytes.Equal(got, whole) {
+		t.Errorf("whole message = %q", got)
+	}
+}
@@ -0,0 +1,108 @@
+package mailmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/emersion/go-imap"
+)
+
+// ParseInboundPayload builds an imap.Message from an inbound-parse payload.
+// Both SendGrid ("email", "headers", "envelope") and Mailgun ("body-mime",
+// "message-headers", "recipient") field names are understood.
+func ParseInboundPayload(fields map[string]string) (*imap.Message, error) {
+	var msg *imap.Message
+	var err error
+
+	switch {
+	case len(fields["email"]) > 0:
+		msg, err = ParseMessage(strings.NewReader(fields["email"]))
+	case len(fields["body-mime"]) > 0:
+		msg, err = ParseMessage(strings.NewReader(fields["body-mime"]))
+	case len(fields["headers"]) > 0:
+		msg, err = ParseMessage(strings.NewReader(strings.TrimRight(fields["headers"], "\r\n") + "\r\n\r\n"))
+	case len(fields["message-headers"]) > 0:
+		msg, err = parseMessageHeadersJSON(fields["message-headers"])
+	default:
+		msg, err = parseInboundFields(fields)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Envelope recipients are not always in the headers (e.g. Bcc)
+	AddRecipients(msg, inboundEnvelopeRecipients(fields))
+
+	if len(msg.Envelope.To) == 0 && len(msg.Envelope.Cc) == 0 && len(msg.Envelope.Bcc) == 0 {
+		return nil, errors.New("inbound payload has no recipients")
+	}
+	return msg, nil
+}
+
+// parseMessageHeadersJSON parses Mailgun's [["Name", "Value"], ...] header list
+func parseMessageHeadersJSON(value string) (*imap.Message, error) {
+	var pairs [][]string
+	if err := json.Unmarshal([]byte(value), &pairs); err != nil {
+		return nil, err
+	}
+	var raw strings.Builder
+	for _, pair := range pairs {
+		if len(pair) != 2 {
+			continue
+		}
+		raw.WriteString(pair[0] + ": " + pair[1] + "\r\n")
+	}
+	raw.WriteString("\r\n")
+	return ParseMessage(strings.NewReader(raw.String()))
+}
+
+// parseInboundFields builds a message from individual form fields
+func parseInboundFields(fields map[string]string) (*imap.Message, error) {
+	var raw strings.Builder
+	for _, key := range []string{"From", "To", "Cc", "Subject", "Date", "Message-Id", "In-Reply-To"} {
+		value := fields[strings.ToLower(key)]
+		if len(value) == 0 {
+			value = fields[key]
+		}
+		if len(value) > 0 {
+			raw.WriteString(key + ": " + value + "\r\n")
+		}
+	}
+	raw.WriteString("\r\n")
+	return ParseMessage(strings.NewReader(raw.String()))
+}
+
+// inboundEnvelopeRecipients returns SMTP envelope recipients of the payload
+func inboundEnvelopeRecipients(fields map[string]string) []string {
+	var recipients []string
+
+	if envelope := fields["envelope"]; len(envelope) > 0 {
+		var v struct {
+			To []string `json:"to"`
+		}
+		if err := json.Unmarshal([]byte(envelope), &v); err == nil {
+			recipients = append(recipients, v.To...)
+		}
+	}
+	if recipient := fields["recipient"]; len(recipient) > 0 {
+		for _, r := range strings.Split(recipient, ",") {
+			recipients = append(recipients, strings.TrimSpace(r))
+		}
+	}
+
+	return recipients
+}
+
+func containsAddress(envelope *imap.Envelope, address string) bool {
+	var addresses []*imap.Address
+	addresses = append(addresses, envelope.To...)
+	addresses = append(addresses, envelope.Cc...)
+	addresses = append(addresses, envelope.Bcc...)
+	for _, addr := range addresses {
+		if strings.EqualFold(addr.MailboxName+"@"+addr.HostName, address) {
+			return true
+		}
+	}
+	return false
+}
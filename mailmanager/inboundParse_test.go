@@ -0,0 +1,93 @@
+package mailmanager
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/emersion/go-imap"
+)
+
+const testInboundMIME = "From: Alice <alice@example.com>\r\n" +
+	"To: taro@example.jp\r\n" +
+	"Subject: Hello\r\n" +
+	"Message-ID: <1@example.com>\r\n" +
+	"\r\n" +
+	"body\r\n"
+
+func inboundRecipients(envelope *imap.Envelope) []string {
+	var recipients []string
+	for _, list := range [][]*imap.Address{envelope.To, envelope.Cc, envelope.Bcc} {
+		for _, addr := range list {
+			recipients = append(recipients, addr.MailboxName+"@"+addr.HostName)
+		}
+	}
+	return recipients
+}
+
+func TestParseInboundPayload(t *testing.T) {
+	tests := []struct {
+		name           string
+		fields         map[string]string
+		wantSubject    string
+		wantRecipients []string
+		wantErr        bool
+	}{
+		{
+			name:           "SendGrid raw MIME with envelope",
+			fields:         map[string]string{"email": testInboundMIME, "envelope": `{"to":["taro@example.jp","hanako@example.jp"],"from":"alice@example.com"}`},
+			wantSubject:    "Hello",
+			wantRecipients: []string{"taro@example.jp", "hanako@example.jp"},
+		},
+		{
+			name:           "SendGrid headers only",
+			fields:         map[string]string{"headers": "From: alice@example.com\nTo: taro@example.jp\nSubject: Headers\n"},
+			wantSubject:    "Headers",
+			wantRecipients: []string{"taro@example.jp"},
+		},
+		{
+			name:           "Mailgun body-mime with recipient",
+			fields:         map[string]string{"body-mime": testInboundMIME, "recipient": "relay@example.net, taro@example.jp"},
+			wantSubject:    "Hello",
+			wantRecipients: []string{"taro@example.jp", "relay@example.net"},
+		},
+		{
+			name:           "Mailgun message-headers",
+			fields:         map[string]string{"message-headers": `[["From","alice@example.com"],["To","taro@example.jp"],["Subject","Mailgun"]]`},
+			wantSubject:    "Mailgun",
+			wantRecipients: []string{"taro@example.jp"},
+		},
+		{
+			name:           "individual fields",
+			fields:         map[string]string{"from": "alice@example.com", "To": "taro@example.jp", "subject": "Fields"},
+			wantSubject:    "Fields",
+			wantRecipients: []string{"taro@example.jp"},
+		},
+		{
+			name:    "no recipients",
+			fields:  map[string]string{"from": "alice@example.com", "subject": "Nobody"},
+			wantErr: true,
+		},
+		{
+			name:    "malformed message-headers",
+			fields:  map[string]string{"message-headers": `{"From":"alice@example.com"}`},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := ParseInboundPayload(tt.fields)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseInboundPayload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if msg.Envelope.Subject != tt.wantSubject {
+				t.Errorf("Subject = %q, want %q", msg.Envelope.Subject, tt.wantSubject)
+			}
+			if got := inboundRecipients(msg.Envelope); !reflect.DeepEqual(got, tt.wantRecipients) {
+				t.Errorf("recipients = %v, want %v", got, tt.wantRecipients)
+			}
+		})
+	}
+}
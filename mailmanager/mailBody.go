@@ -0,0 +1,83 @@
+package mailmanager
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+var (
+	htmlInvisibleRegexp = regexp.MustCompile(`(?is)<(script|style|head)[^>]*>.*?</(script|style|head)>`)
+	htmlBreakRegexp     = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|tr|li|h[1-6])>`)
+	htmlTagRegexp       = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankLinesRegexp    = regexp.MustCompile(`\n{3,}`)
+)
+
+// ExtractText returns the decoded text body of a raw message.
+// text/plain is preferred; a text/html body is converted to plain text.
+func ExtractText(raw []byte) (string, error) {
+	root, err := parseMIME(raw)
+	if err != nil {
+		return "", err
+	}
+
+	var plain, htmlPart *mimePart
+	root.walk(func(part *mimePart) {
+		if part.isAttachment() {
+			return
+		}
+		switch part.MediaType {
+		case "text/plain":
+			if plain == nil {
+				plain = part
+			}
+		case "text/html":
+			if htmlPart == nil {
+				htmlPart = part
+			}
+		}
+	})
+
+	switch {
+	case plain != nil:
+		return normalizeNewlines(decodeCharset(plain)), nil
+	case htmlPart != nil:
+		return HTMLToText(decodeCharset(htmlPart)), nil
+	}
+	return "", errors.New("no text body")
+}
+
+// decodeCharset converts the part body to UTF-8, keeping the bytes on failure
+func decodeCharset(part *mimePart) string {
+	r, err := CharsetReader(part.Params["charset"], bytes.NewReader(part.Body))
+	if err != nil {
+		return string(part.Body)
+	}
+	decoded, err := ioutil.ReadAll(r)
+	if err != nil {
+		return string(part.Body)
+	}
+	return string(decoded)
+}
+
+func normalizeNewlines(text string) string {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	return strings.TrimSpace(text)
+}
+
+// HTMLToText strips tags from an HTML body
+func HTMLToText(body string) string {
+	body = htmlInvisibleRegexp.ReplaceAllString(body, "")
+	body = htmlBreakRegexp.ReplaceAllString(body, "\n")
+	body = htmlTagRegexp.ReplaceAllString(body, "")
+	body = html.UnescapeString(normalizeNewlines(body))
+
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return blankLinesRegexp.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+}
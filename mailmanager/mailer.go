@@ -0,0 +1,210 @@
+package mailmanager
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+)
+
+// Outbound mail transports
+const (
+	TransportSMTP  = "smtp"
+	TransportHTTP  = "http"
+	TransportSpool = "spool"
+	TransportLog   = "log"
+)
+
+// Mailer sends outgoing messages
+type Mailer interface {
+	Send(msg *OutgoingMessage) error
+}
+
+var (
+	mailerMutex sync.RWMutex
+	mailer      Mailer
+)
+
+// SetMailer overrides the Mailer returned by CurrentMailer. nil restores MAIL_TRANSPORT.
+func SetMailer(m Mailer) {
+	mailerMutex.Lock()
+	defer mailerMutex.Unlock()
+	mailer = m
+}
+
+// CurrentMailer returns the Mailer set by SetMailer, or the one selected by
+// MAIL_TRANSPORT (default: smtp)
+func CurrentMailer() Mailer {
+	mailerMutex.RLock()
+	m := mailer
+	mailerMutex.RUnlock()
+	if m != nil {
+		return m
+	}
+
+	configVars := helper.ConfigVars()
+	signer := CurrentDKIMSigner()
+	switch strings.ToLower(configVars.MailTransport.Transport) {
+	case TransportHTTP:
+		return &HTTPMailer{
+			URL:   configVars.MailTransport.HTTPURL,
+			Token: configVars.MailTransport.HTTPToken,
+			DKIM:  signer,
+		}
+	case TransportSpool:
+		return &SpoolMailer{Dir: configVars.MailTransport.SpoolDir, DKIM: signer}
+	case TransportLog:
+		return &SpoolMailer{DKIM: signer, LogBody: configVars.MailTransport.LogBody == "true"}
+	}
+	return &SMTPMailer{Options: CurrentSMTPOptions(), DKIM: signer}
+}
+
+// renderMessage renders msg and signs it when signer is set
+func renderMessage(msg *OutgoingMessage, signer *DKIMSigner) ([]byte, error) {
+	message, err := msg.Bytes()
+	if err != nil || signer == nil {
+		return message, err
+	}
+	return signer.Sign(message)
+}
+
+// SMTPMailer sends messages through an SMTP submission server
+type SMTPMailer struct {
+	Options SMTPOptions
+	DKIM    *DKIMSigner
+}
+
+// Send ..
+func (m *SMTPMailer) Send(msg *OutgoingMessage) error {
+	message, err := renderMessage(msg, m.DKIM)
+	if err != nil {
+		return err
+	}
+	return sendSMTP(message, msg.EnvelopeFrom(), msg.Recipients(), m.Options)
+}
+
+// HTTPMailer posts messages to a HTTP mail API as JSON:
+// {"from": envelope from, "to": [recipients], "raw": base64 RFC 5322 message}
+type HTTPMailer struct {
+	URL string
+	// Token is sent as a Bearer token when set
+	Token  string
+	Client *http.Client
+	DKIM   *DKIMSigner
+}
+
+// httpMailRequest ..
+type httpMailRequest struct {
+	From string   `json:"from"`
+	To   []string `json:"to"`
+	Raw  string   `json:"raw"`
+}
+
+// Send ..
+func (m *HTTPMailer) Send(msg *OutgoingMessage) error {
+	if len(m.URL) == 0 {
+		return errors.New("mail http api: URL is not set")
+	}
+	message, err := renderMessage(msg, m.DKIM)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(httpMailRequest{
+		From: msg.EnvelopeFrom(),
+		To:   msg.Recipients(),
+		Raw:  base64.StdEncoding.EncodeToString(message),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, m.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(m.Token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+m.Token)
+	}
+
+	client := m.Client
+	if client == nil {
+		client = &http.Client{Timeout: defaultSMTPTimeout}
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		detail, _ := ioutil.ReadAll(res.Body)
+		return errors.New("mail http api: " + res.Status + " " + strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// SpoolMailer writes messages to Dir as .eml files for development.
+// When Dir is empty only the header is logged, since bodies carry
+// verification codes; LogBody logs the whole message for debugging.
+type SpoolMailer struct {
+	Dir     string
+	DKIM    *DKIMSigner
+	LogBody bool
+}
+
+// Send ..
+func (m *SpoolMailer) Send(msg *OutgoingMessage) error {
+	message, err := renderMessage(msg, m.DKIM)
+	if err != nil {
+		return err
+	}
+	if len(m.Dir) == 0 {
+		if !m.LogBody {
+			if i := bytes.Index(message, []byte("\r\n\r\n")); i >= 0 {
+				message = append(message[:i+4:i+4], "[body redacted, set MAIL_LOG_BODY=true to log it]"...)
+			}
+		}
+		log.Printf("mail to %s from %s\n%s", strings.Join(msg.Recipients(), ", "), msg.EnvelopeFrom(), message)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.Dir, 0700); err != nil {
+		return err
+	}
+	name := time.Now().Format("20060102-150405.000000000") + ".eml"
+	return ioutil.WriteFile(filepath.Join(m.Dir, name), message, 0600)
+}
+
+// RecordingMailer keeps sent messages in memory instead of sending them
+type RecordingMailer struct {
+	mutex    sync.Mutex
+	messages []*OutgoingMessage
+}
+
+// Send ..
+func (m *RecordingMailer) Send(msg *OutgoingMessage) error {
+	if _, err := msg.Bytes(); err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far
+func (m *RecordingMailer) Messages() []*OutgoingMessage {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]*OutgoingMessage(nil), m.messages...)
+}
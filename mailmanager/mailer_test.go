@@ -0,0 +1,57 @@
+package mailmanager
+
+import (
+	"bytes"
+	"log"
+	"net/mail"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSpoolMailerLogRedactsBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		logBody bool
+		want    bool
+	}{
+		{name: "redacted", logBody: false, want: false},
+		{name: "debug", logBody: true, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log.SetOutput(&buf)
+			defer log.SetOutput(os.Stderr)
+
+			msg := &OutgoingMessage{
+				From:    mail.Address{Address: "bot@example.com"},
+				To:      []mail.Address{{Address: "alice@example.org"}},
+				Subject: "Verification",
+				Text:    "code: VC-secret",
+			}
+			if err := (&SpoolMailer{LogBody: tt.logBody}).Send(msg); err != nil {
+				t.Fatal(err)
+			}
+			output := buf.String()
+			if !strings.Contains(output, "alice@example.org") || !strings.Contains(output, "Subject: Verification") {
+				t.Errorf("header is not logged: %q", output)
+			}
+			if got := strings.Contains(output, "VC-secret"); got != tt.want {
+				t.Errorf("body logged = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetMailer(t *testing.T) {
+	recorder := &RecordingMailer{}
+	SetMailer(recorder)
+	if CurrentMailer() != Mailer(recorder) {
+		t.Fatal("CurrentMailer does not return the mailer set by SetMailer")
+	}
+	SetMailer(nil)
+	if _, ok := CurrentMailer().(*RecordingMailer); ok {
+		t.Fatal("SetMailer(nil) does not restore MAIL_TRANSPORT")
+	}
+}
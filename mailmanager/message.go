@@ -0,0 +1,365 @@
+package mailmanager
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+// Charsets of outgoing text
+const (
+	CharsetUTF8      = "UTF-8"
+	CharsetISO2022JP = "ISO-2022-JP"
+)
+
+const (
+	maxHeaderLineLength = 78
+	base64LineLength    = 76
+	// iso2022jpWordRunes and utf8WordBytes keep encoded-words short enough
+	// to follow a header name within the line length
+	iso2022jpWordRunes = 12
+	utf8WordBytes      = 39
+)
+
+// OutgoingMessage builds a RFC 5322/MIME compliant message
+type OutgoingMessage struct {
+	From    mail.Address
+	Sender  *mail.Address
+	To      []mail.Address
+	Cc      []mail.Address
+	ReplyTo []mail.Address
+	Subject string
+	// Text is the plain text body. HTML, if set, is sent as multipart/alternative.
+	Text string
+	HTML string
+	// Charset is CharsetUTF8 (default) or CharsetISO2022JP
+	Charset string
+	// Headers are extra headers such as In-Reply-To and References
+	Headers map[string]string
+	// Date and MessageID are generated when empty
+	Date      time.Time
+	MessageID string
+}
+
+// EnvelopeFrom returns the address used for MAIL FROM
+func (m *OutgoingMessage) EnvelopeFrom() string {
+	if m.Sender != nil {
+		return m.Sender.Address
+	}
+	return m.From.Address
+}
+
+// Recipients returns the addresses used for RCPT TO
+func (m *OutgoingMessage) Recipients() []string {
+	var recipients []string
+	for _, list := range [][]mail.Address{m.To, m.Cc} {
+		for _, address := range list {
+			recipients = append(recipients, address.Address)
+		}
+	}
+	return recipients
+}
+
+// charset returns the charset to encode with. ISO-2022-JP falls back to UTF-8
+// when the message has characters outside JIS X 0208 (e.g. emoji).
+func (m *OutgoingMessage) charset() string {
+	if !strings.EqualFold(m.Charset, CharsetISO2022JP) {
+		return CharsetUTF8
+	}
+	for _, content := range []string{m.Subject, m.Text, m.HTML} {
+		if _, err := japanese.ISO2022JP.NewEncoder().String(content); err != nil {
+			return CharsetUTF8
+		}
+	}
+	return CharsetISO2022JP
+}
+
+// Bytes renders the message with CRLF line endings
+func (m *OutgoingMessage) Bytes() ([]byte, error) {
+	if len(m.From.Address) == 0 {
+		return nil, errors.New("message has no From address")
+	}
+	if len(m.Recipients()) == 0 {
+		return nil, errors.New("message has no recipients")
+	}
+
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	messageID := m.MessageID
+	if len(messageID) == 0 {
+		messageID = GenerateMessageID(m.From.Address)
+	}
+
+	var h headerWriter
+	h.write("Date", date.Format(time.RFC1123Z))
+	h.write("From", m.From.String())
+	if m.Sender != nil {
+		h.write("Sender", m.Sender.String())
+	}
+	h.write("To", formatAddressList(m.To))
+	if len(m.Cc) > 0 {
+		h.write("Cc", formatAddressList(m.Cc))
+	}
+	if len(m.ReplyTo) > 0 {
+		h.write("Reply-To", formatAddressList(m.ReplyTo))
+	}
+	subject, err := m.encodeWord(m.Subject)
+	if err != nil {
+		return nil, err
+	}
+	h.write("Subject", subject)
+	h.write("Message-ID", messageID)
+
+	keys := make([]string, 0, len(m.Headers))
+	for key := range m.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if value := m.Headers[key]; len(value) > 0 {
+			h.write(textproto.CanonicalMIMEHeaderKey(key), value)
+		}
+	}
+	h.write("MIME-Version", "1.0")
+
+	if len(m.HTML) == 0 {
+		header, body, err := m.textPart("text/plain", m.Text)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+			h.write(key, header.Get(key))
+		}
+		if h.err != nil {
+			return nil, h.err
+		}
+		h.buf.WriteString("\r\n")
+		h.buf.Write(body)
+		return h.buf.Bytes(), nil
+	}
+
+	var parts bytes.Buffer
+	w := multipart.NewWriter(&parts)
+	for _, p := range []struct{ mediaType, content string }{{"text/plain", m.Text}, {"text/html", m.HTML}} {
+		header, body, err := m.textPart(p.mediaType, p.content)
+		if err != nil {
+			return nil, err
+		}
+		pw, err := w.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		pw.Write(body)
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	h.write("Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": w.Boundary()}))
+	if h.err != nil {
+		return nil, h.err
+	}
+	h.buf.WriteString("\r\n")
+	h.buf.Write(parts.Bytes())
+	return h.buf.Bytes(), nil
+}
+
+// textPart encodes content in the message charset with a suitable transfer encoding
+func (m *OutgoingMessage) textPart(mediaType, content string) (textproto.MIMEHeader, []byte, error) {
+	content = strings.Replace(content, "\r\n", "\n", -1)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", mime.FormatMediaType(mediaType, map[string]string{"charset": m.charset()}))
+
+	var body bytes.Buffer
+	switch {
+	case m.charset() == CharsetISO2022JP:
+		encoded, err := japanese.ISO2022JP.NewEncoder().String(content)
+		if err != nil {
+			return nil, nil, err
+		}
+		header.Set("Content-Transfer-Encoding", "7bit")
+		body.WriteString(strings.Replace(encoded, "\n", "\r\n", -1))
+	case isASCII(content):
+		header.Set("Content-Transfer-Encoding", "7bit")
+		body.WriteString(strings.Replace(content, "\n", "\r\n", -1))
+	case nonASCIIRatio(content) > 0.3:
+		header.Set("Content-Transfer-Encoding", "base64")
+		writeBase64Lines(&body, []byte(content))
+	default:
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		qp := quotedprintable.NewWriter(&body)
+		qp.Write([]byte(content))
+		qp.Close()
+	}
+	return header, body.Bytes(), nil
+}
+
+// encodeWord encodes a header value as RFC 2047 encoded-words when needed.
+// Encoded-words are separated by white space, where writeHeader folds them.
+func (m *OutgoingMessage) encodeWord(value string) (string, error) {
+	if isASCII(value) {
+		return value, nil
+	}
+	var words []string
+	if m.charset() != CharsetISO2022JP {
+		// Words are split between characters, never inside one
+		for len(value) > 0 {
+			n := len(value)
+			if n > utf8WordBytes {
+				for i := range value {
+					if i > utf8WordBytes {
+						break
+					}
+					if i > 0 {
+						n = i
+					}
+				}
+			}
+			words = append(words, mime.BEncoding.Encode(CharsetUTF8, value[:n]))
+			value = value[n:]
+		}
+		return strings.Join(words, " "), nil
+	}
+
+	// Each word is encoded separately so escape sequences never split
+	runes := []rune(value)
+	for len(runes) > 0 {
+		n := iso2022jpWordRunes
+		if n > len(runes) {
+			n = len(runes)
+		}
+		encoded, err := japanese.ISO2022JP.NewEncoder().String(string(runes[:n]))
+		if err != nil {
+			return "", err
+		}
+		words = append(words, "=?"+CharsetISO2022JP+"?B?"+base64.StdEncoding.EncodeToString([]byte(encoded))+"?=")
+		runes = runes[n:]
+	}
+	return strings.Join(words, " "), nil
+}
+
+// GenerateMessageID returns a unique Message-ID in the domain of address
+func GenerateMessageID(address string) string {
+	domain := AddressDomain(address)
+	if len(domain) == 0 {
+		domain = "localhost"
+	}
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "<" + time.Now().Format("20060102150405.000000000") + "@" + domain + ">"
+	}
+	return "<" + time.Now().Format("20060102150405") + "." + hex.EncodeToString(random) + "@" + domain + ">"
+}
+
+func formatAddressList(addresses []mail.Address) string {
+	formatted := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		formatted = append(formatted, address.String())
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// headerWriter writes header fields and keeps the first error
+type headerWriter struct {
+	buf bytes.Buffer
+	err error
+}
+
+func (w *headerWriter) write(key, value string) {
+	if w.err == nil {
+		w.err = writeHeader(&w.buf, key, value)
+	}
+}
+
+// writeHeader writes a header field, folding long values at white space.
+// A key or value that would break the header block is an error.
+func writeHeader(buf *bytes.Buffer, key, value string) error {
+	if !validHeaderKey(key) {
+		return fmt.Errorf("invalid header field name %q", key)
+	}
+	if strings.ContainsAny(value, "\r\n\x00") {
+		return fmt.Errorf("header field %s has a line break or NUL", key)
+	}
+	line := key + ":"
+	lineLength := len(line)
+	for i, word := range strings.Split(value, " ") {
+		if i > 0 && lineLength+1+len(word) > maxHeaderLineLength {
+			line += "\r\n"
+			lineLength = 0
+		}
+		line += " " + word
+		lineLength += 1 + len(word)
+	}
+	buf.WriteString(line + "\r\n")
+	return nil
+}
+
+// validHeaderKey reports whether key is a RFC 5322 field name
+func validHeaderKey(key string) bool {
+	if len(key) == 0 {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < '!' || key[i] > '~' || key[i] == ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// sanitizeHeaderText replaces CR, LF and NUL with spaces, e.g. in a decoded
+// Subject copied into another message
+func sanitizeHeaderText(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' || r == 0 {
+			return ' '
+		}
+		return r
+	}, value)
+}
+
+func writeBase64Lines(buf *bytes.Buffer, content []byte) {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > base64LineLength {
+		buf.WriteString(encoded[:base64LineLength] + "\r\n")
+		encoded = encoded[base64LineLength:]
+	}
+	buf.WriteString(encoded + "\r\n")
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func nonASCIIRatio(s string) float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			n++
+		}
+	}
+	return float64(n) / float64(len(s))
+}
@@ -0,0 +1,32 @@
+package mailmanager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/emersion/go-imap"
+)
+
+// MessageKey identifies a message across fetch cycles and mail sources.
+// The Message-ID is used when present, otherwise a hash of the envelope.
+func MessageKey(msg imap.Message) string {
+	if msg.Envelope == nil {
+		return ""
+	}
+	messageID := strings.TrimSpace(msg.Envelope.MessageId)
+	messageID = strings.TrimSuffix(strings.TrimPrefix(messageID, "<"), ">")
+	if len(messageID) > 0 {
+		return "mid:" + messageID
+	}
+
+	h := sha256.New()
+	h.Write([]byte(msg.Envelope.Date.UTC().String() + "\n"))
+	h.Write([]byte(msg.Envelope.Subject + "\n"))
+	for _, list := range [][]*imap.Address{msg.Envelope.From, msg.Envelope.To, msg.Envelope.Cc} {
+		for _, addr := range list {
+			h.Write([]byte(addr.MailboxName + "@" + addr.HostName + "\n"))
+		}
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,110 @@
+package mailmanager
+
+import (
+	"bytes"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestReplyHeaderInjection(t *testing.T) {
+	raw := strings.Join([]string{
+		"From: Mallory <mallory@example.org>",
+		"To: alice@example.com",
+		"Subject: =?us-ascii?Q?x=0D=0ABcc:_victim@example.com?=",
+		"Message-ID: <1@example.org>",
+		"",
+		"hello",
+		"",
+	}, "\r\n")
+	target, err := NewReplyTarget([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.ContainsAny(target.Subject, "\r\n") {
+		t.Fatalf("Subject keeps line breaks: %q", target.Subject)
+	}
+
+	msg := &OutgoingMessage{
+		From:    mail.Address{Address: "bot@example.com"},
+		To:      []mail.Address{{Address: target.To.Address}},
+		Subject: target.Subject,
+		Text:    "reply",
+		Headers: map[string]string{"In-Reply-To": target.InReplyTo, "References": target.References},
+	}
+	b, err := msg.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bcc := parsed.Header.Get("Bcc"); len(bcc) > 0 {
+		t.Errorf("injected Bcc header: %q", bcc)
+	}
+	if got := parsed.Header.Get("Subject"); !strings.Contains(got, "Bcc: victim@example.com") {
+		t.Errorf("Subject = %q", got)
+	}
+}
+
+func TestSubjectFolding(t *testing.T) {
+	for _, charset := range []string{CharsetUTF8, CharsetISO2022JP} {
+		t.Run(charset, func(t *testing.T) {
+			msg := &OutgoingMessage{
+				From:    mail.Address{Address: "bot@example.com"},
+				To:      []mail.Address{{Address: "alice@example.com"}},
+				Subject: strings.Repeat("お知らせメールの件名です。", 8),
+				Text:    "本文",
+				Charset: charset,
+			}
+			b, err := msg.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := string(b[:bytes.Index(b, []byte("\r\n\r\n"))])
+			for _, line := range strings.Split(header, "\r\n") {
+				if len(line) > maxHeaderLineLength {
+					t.Errorf("line longer than %d: %q", maxHeaderLineLength, line)
+				}
+			}
+			parsed, err := mail.ReadMessage(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(parsed.Header.Get("Subject")) == 0 {
+				t.Error("Subject is missing")
+			}
+		})
+	}
+}
+
+func TestBytesRejectsBrokenHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+		headers map[string]string
+	}{
+		{name: "CRLF in Subject", subject: "x\r\nBcc: victim@example.com"},
+		{name: "bare LF in Subject", subject: "x\nBcc: victim@example.com"},
+		{name: "NUL in Subject", subject: "x\x00y"},
+		{name: "CRLF in extra header", subject: "ok", headers: map[string]string{"References": "<1@example.org>\r\nBcc: victim@example.com"}},
+		{name: "bare CR in extra header", subject: "ok", headers: map[string]string{"In-Reply-To": "<1@example.org>\rBcc: victim@example.com"}},
+		{name: "colon in header name", subject: "ok", headers: map[string]string{"Bcc: victim@example.com\r\nX": "1"}},
+		{name: "space in header name", subject: "ok", headers: map[string]string{"X Test": "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &OutgoingMessage{
+				From:    mail.Address{Address: "bot@example.com"},
+				To:      []mail.Address{{Address: "alice@example.com"}},
+				Subject: tt.subject,
+				Text:    "body",
+				Headers: tt.headers,
+			}
+			if b, err := msg.Bytes(); err == nil {
+				t.Errorf("Bytes() = %q, want an error", b)
+			}
+		})
+	}
+}
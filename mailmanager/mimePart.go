@@ -0,0 +1,142 @@
+package mailmanager
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// maxMIMEDepth limits nesting of multipart bodies
+const maxMIMEDepth = 10
+
+// mimePart is a node of a parsed MIME tree.
+// Path uses IMAP section numbering ("1", "2.1", ...).
+type mimePart struct {
+	Path      string
+	Header    textproto.MIMEHeader
+	MediaType string
+	Params    map[string]string
+	Body      []byte
+	Parts     []*mimePart
+}
+
+// parseMIME parses a raw RFC 5322 message into a MIME tree
+func parseMIME(raw []byte) (*mimePart, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	root := &mimePart{Header: textproto.MIMEHeader(m.Header)}
+	if err := root.parseBody(m.Body, 0); err != nil {
+		return nil, err
+	}
+	// A single part message has its body at section "1"
+	if len(root.Parts) == 0 {
+		root.Path = "1"
+	}
+	return root, nil
+}
+
+func (p *mimePart) parseBody(body io.Reader, depth int) error {
+	mediaType, params, err := mime.ParseMediaType(p.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{"charset": "us-ascii"}
+	}
+	p.MediaType = mediaType
+	p.Params = params
+
+	if strings.HasPrefix(mediaType, "multipart/") && len(params["boundary"]) > 0 && depth < maxMIMEDepth {
+		mr := multipart.NewReader(body, params["boundary"])
+		for i := 1; ; i++ {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			child := &mimePart{
+				Path:   childPath(p.Path, i),
+				Header: part.Header,
+			}
+			if err := child.parseBody(part, depth+1); err != nil {
+				return err
+			}
+			p.Parts = append(p.Parts, child)
+		}
+		return nil
+	}
+
+	decoded, err := ioutil.ReadAll(decodeTransferEncoding(body, p.Header.Get("Content-Transfer-Encoding")))
+	if err != nil {
+		return err
+	}
+	p.Body = decoded
+	return nil
+}
+
+func childPath(parent string, n int) string {
+	if len(parent) == 0 {
+		return strconv.Itoa(n)
+	}
+	return parent + "." + strconv.Itoa(n)
+}
+
+// decodeTransferEncoding wraps body with a Content-Transfer-Encoding decoder
+func decodeTransferEncoding(body io.Reader, encoding string) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	default:
+		return body
+	}
+}
+
+// walk calls fn for every leaf part
+func (p *mimePart) walk(fn func(part *mimePart)) {
+	if len(p.Parts) == 0 {
+		fn(p)
+		return
+	}
+	for _, child := range p.Parts {
+		child.walk(fn)
+	}
+}
+
+// find returns the leaf part at path
+func (p *mimePart) find(path string) *mimePart {
+	var found *mimePart
+	p.walk(func(part *mimePart) {
+		if part.Path == path {
+			found = part
+		}
+	})
+	return found
+}
+
+// fileName returns the decoded file name of the part, if any
+func (p *mimePart) fileName() string {
+	if _, params, err := mime.ParseMediaType(p.Header.Get("Content-Disposition")); err == nil && len(params["filename"]) > 0 {
+		return DecodeHeader(params["filename"])
+	}
+	return DecodeHeader(p.Params["name"])
+}
+
+// isAttachment reports whether the part is an attachment rather than a body text
+func (p *mimePart) isAttachment() bool {
+	disposition, _, _ := mime.ParseMediaType(p.Header.Get("Content-Disposition"))
+	if disposition == "attachment" {
+		return true
+	}
+	return len(p.fileName()) > 0 && !strings.HasPrefix(p.MediaType, "text/")
+}
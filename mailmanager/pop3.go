@@ -0,0 +1,195 @@
+package mailmanager
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+)
+
+const pop3Timeout = 2 * time.Minute
+
+// pop3Client is a minimal RFC 1939 client
+type pop3Client struct {
+	conn net.Conn
+	text *textproto.Conn
+}
+
+func dialPOP3TLS(pop3ServerName string) (*pop3Client, error) {
+	host, _, err := net.SplitHostPort(pop3ServerName)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: pop3Timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", pop3ServerName, &tls.Config{ServerName: host})
+	if err != nil {
+		return nil, err
+	}
+	c := &pop3Client{conn: conn, text: textproto.NewConn(conn)}
+	if _, err := c.readResponse(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// readResponse reads a single "+OK"/"-ERR" status line
+func (c *pop3Client) readResponse() (string, error) {
+	c.conn.SetDeadline(time.Now().Add(pop3Timeout))
+	line, err := c.text.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(line, "+OK") {
+		return strings.TrimSpace(strings.TrimPrefix(line, "+OK")), nil
+	}
+	return "", errors.New("pop3: " + line)
+}
+
+func (c *pop3Client) cmd(format string, args ...interface{}) (string, error) {
+	c.conn.SetDeadline(time.Now().Add(pop3Timeout))
+	if err := c.text.PrintfLine(format, args...); err != nil {
+		return "", err
+	}
+	return c.readResponse()
+}
+
+// cmdMultiline sends a command and returns a dot-terminated response body
+func (c *pop3Client) cmdMultiline(format string, args ...interface{}) (io.Reader, error) {
+	if _, err := c.cmd(format, args...); err != nil {
+		return nil, err
+	}
+	return c.text.DotReader(), nil
+}
+
+func (c *pop3Client) login(user, password string) error {
+	if _, err := c.cmd("USER %s", user); err != nil {
+		return err
+	}
+	_, err := c.cmd("PASS %s", password)
+	return err
+}
+
+// uidl returns unique ids keyed by message number
+func (c *pop3Client) uidl() (map[int]string, error) {
+	if _, err := c.cmd("UIDL"); err != nil {
+		return nil, err
+	}
+	lines, err := c.text.ReadDotLines()
+	if err != nil {
+		return nil, err
+	}
+	uidls := make(map[int]string, len(lines))
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		n, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		uidls[n] = fields[1]
+	}
+	return uidls, nil
+}
+
+func (c *pop3Client) quit() error {
+	_, err := c.cmd("QUIT")
+	c.conn.Close()
+	return err
+}
+
+// PopMailPOP3 :fetch new mails using pop3s and optionally delete them.
+// UIDLs of fetched mails are kept in MongoDB so kept mails are not fetched twice.
+// Connection errors are returned so the caller can try again later. When a
+// RETR fails, the session is closed without QUIT so that nothing is deleted,
+// and the mails fetched before it are returned along with the error.
+func PopMailPOP3(deleteMail bool, pop3ServerName, pop3AuthUser, pop3AuthPassword, mongodbURL string) ([]imap.Message, error) {
+	c, err := dialPOP3TLS(pop3ServerName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.login(pop3AuthUser, pop3AuthPassword); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+
+	uidls, err := c.uidl()
+	if err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+
+	account := pop3AuthUser + "@" + pop3ServerName
+	fetchedUIDLs := mongodb.ReadPop3FetchedUIDLs(account, mongodbURL)
+
+	numbers := make([]int, 0, len(uidls))
+	for n := range uidls {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	var messageEntities []imap.Message
+	currentUIDLs := make([]string, 0, len(uidls))
+	for _, n := range numbers {
+		uidl := uidls[n]
+		currentUIDLs = append(currentUIDLs, uidl)
+
+		fetched := fetchedUIDLs[uidl]
+		if !fetched {
+			r, err := c.cmdMultiline("RETR %d", n)
+			if err != nil {
+				// The connection may be out of sync, so no more commands are sent
+				c.conn.Close()
+				return messageEntities, err
+			}
+			msg, err := ParseMessage(r)
+			// Drain the rest of the message so the next command is in sync
+			if _, drainErr := io.Copy(ioutil.Discard, r); drainErr != nil {
+				c.conn.Close()
+				return messageEntities, drainErr
+			}
+			if err != nil {
+				// Kept on the server and retried, as the mail was not delivered
+				log.Print(err)
+			} else {
+				messageEntities = append(messageEntities, *msg)
+				mongodb.CreateOrUpdatePop3FetchedMessage(mongodb.Pop3FetchedMessage{
+					Account:   account,
+					UIDL:      uidl,
+					FetchedAt: time.Now(),
+				}, mongodbURL)
+				fetched = true
+			}
+		}
+
+		// Mails fetched in an interrupted session are deleted as well
+		if deleteMail && fetched {
+			if _, err := c.cmd("DELE %d", n); err != nil {
+				log.Print(err)
+			}
+		}
+	}
+
+	// Deleted mails are removed at QUIT
+	if err := c.quit(); err != nil {
+		log.Print(err)
+	}
+
+	// Forget UIDLs of mails which had already gone before this session
+	mongodb.DeletePop3FetchedMessagesExcept(account, currentUIDLs, mongodbURL)
+
+	return messageEntities, nil
+}
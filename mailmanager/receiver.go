@@ -0,0 +1,348 @@
+package mailmanager
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+)
+
+const (
+	receiverCommandTimeout = 5 * time.Minute
+	receiverMaxRecipients  = 100
+)
+
+// MailReceiver accepts inbound mail over SMTP (or LMTP) and passes it to Handler
+type MailReceiver struct {
+	// Addr is the TCP address to listen on, e.g. ":2525"
+	Addr string
+	// Domain is the relay domain. Any recipient in it is accepted (catch-all).
+	Domain string
+	// RelayAddresses are accepted as well, e.g. IMAP_ADDRESS in another domain
+	RelayAddresses []string
+	// LMTP switches the greeting and replies to RFC 2033 LMTP
+	LMTP bool
+	// MaxMessageBytes rejects messages larger than this size. 0 means unlimited.
+	MaxMessageBytes int64
+	// ValidRecipient reports whether a recipient outside the relay is
+	// accepted, e.g. a registered address delivered directly
+	ValidRecipient func(address string) bool
+	// Handler is called for every accepted message with its RCPT TO addresses
+	Handler func(msg imap.Message, recipients []string)
+}
+
+// ListenAndServe listens on r.Addr and serves incoming connections
+func (r *MailReceiver) ListenAndServe() error {
+	l, err := net.Listen("tcp", r.Addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				log.Print("MailReceiver: ", err)
+				time.Sleep(time.Second)
+				continue
+			}
+			return err
+		}
+		go r.serve(conn)
+	}
+}
+
+// receiverSession holds the state of a single SMTP/LMTP transaction
+type receiverSession struct {
+	receiver   *MailReceiver
+	conn       net.Conn
+	text       *textproto.Conn
+	helo       string
+	from       string
+	recipients []string
+}
+
+func (r *MailReceiver) serve(conn net.Conn) {
+	defer conn.Close()
+
+	s := &receiverSession{
+		receiver: r,
+		conn:     conn,
+		text:     textproto.NewConn(conn),
+	}
+
+	s.reply(220, r.hostname()+" "+r.protocol()+" Service ready")
+	for {
+		conn.SetDeadline(time.Now().Add(receiverCommandTimeout))
+		line, err := s.text.ReadLine()
+		if err != nil {
+			if err != io.EOF {
+				log.Print("MailReceiver: ", err)
+			}
+			return
+		}
+
+		verb, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			verb, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+
+		switch strings.ToUpper(verb) {
+		case "HELO", "EHLO":
+			if r.LMTP {
+				s.reply(500, "This is a LMTP server, use LHLO")
+				continue
+			}
+			s.handleHello(strings.ToUpper(verb), arg)
+		case "LHLO":
+			if !r.LMTP {
+				s.reply(500, "This is a SMTP server, use EHLO")
+				continue
+			}
+			s.handleHello("LHLO", arg)
+		case "MAIL":
+			s.handleMail(arg)
+		case "RCPT":
+			s.handleRcpt(arg)
+		case "DATA":
+			s.handleData()
+		case "RSET":
+			s.reset()
+			s.reply(250, "OK")
+		case "NOOP":
+			s.reply(250, "OK")
+		case "VRFY":
+			s.reply(252, "Cannot VRFY user")
+		case "QUIT":
+			s.reply(221, "Bye")
+			return
+		default:
+			s.reply(502, "Command not implemented")
+		}
+	}
+}
+
+func (r *MailReceiver) protocol() string {
+	if r.LMTP {
+		return "LMTP"
+	}
+	return "ESMTP"
+}
+
+func (r *MailReceiver) hostname() string {
+	if len(r.Domain) > 0 {
+		return r.Domain
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "localhost"
+	}
+	return hostname
+}
+
+func (s *receiverSession) reply(code int, lines ...string) {
+	for i, line := range lines {
+		sep := " "
+		if i < len(lines)-1 {
+			sep = "-"
+		}
+		if err := s.text.PrintfLine("%d%s%s", code, sep, line); err != nil {
+			log.Print("MailReceiver: ", err)
+			return
+		}
+	}
+}
+
+func (s *receiverSession) reset() {
+	s.from = ""
+	s.recipients = nil
+}
+
+func (s *receiverSession) handleHello(verb, arg string) {
+	if len(arg) == 0 {
+		s.reply(501, "Domain/address argument required")
+		return
+	}
+	s.reset()
+	s.helo = arg
+
+	if verb == "HELO" {
+		s.reply(250, s.receiver.hostname())
+		return
+	}
+	lines := []string{s.receiver.hostname(), "PIPELINING", "8BITMIME"}
+	if s.receiver.MaxMessageBytes > 0 {
+		lines = append(lines, "SIZE "+strconv.FormatInt(s.receiver.MaxMessageBytes, 10))
+	}
+	s.reply(250, lines...)
+}
+
+func (s *receiverSession) handleMail(arg string) {
+	if len(s.helo) == 0 {
+		s.reply(503, "Send HELO/EHLO first")
+		return
+	}
+	if len(s.from) > 0 {
+		s.reply(503, "Sender already specified")
+		return
+	}
+	path, params, ok := parsePath(arg, "FROM:")
+	if !ok {
+		s.reply(501, "Syntax: MAIL FROM:<address>")
+		return
+	}
+	if size, ok := params["SIZE"]; ok && s.receiver.MaxMessageBytes > 0 {
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			s.reply(501, "Invalid SIZE parameter")
+			return
+		}
+		if n > s.receiver.MaxMessageBytes {
+			s.reply(552, "Message size exceeds fixed maximum message size")
+			return
+		}
+	}
+	// Null reverse-path is allowed for bounces
+	s.from = "<" + path + ">"
+	s.reply(250, "OK")
+}
+
+func (s *receiverSession) handleRcpt(arg string) {
+	if len(s.from) == 0 {
+		s.reply(503, "Need MAIL before RCPT")
+		return
+	}
+	if len(s.recipients) >= receiverMaxRecipients {
+		s.reply(452, "Too many recipients")
+		return
+	}
+	path, _, ok := parsePath(arg, "TO:")
+	if !ok || len(path) == 0 {
+		s.reply(501, "Syntax: RCPT TO:<address>")
+		return
+	}
+	address, err := mail.ParseAddress("<" + path + ">")
+	if err != nil {
+		s.reply(501, "Invalid recipient address")
+		return
+	}
+	if !s.receiver.acceptRecipient(address.Address) {
+		s.reply(550, "No such user here")
+		return
+	}
+	s.recipients = append(s.recipients, address.Address)
+	s.reply(250, "OK")
+}
+
+func (s *receiverSession) handleData() {
+	if len(s.recipients) == 0 {
+		s.reply(503, "Need RCPT before DATA")
+		return
+	}
+	s.reply(354, "Start mail input; end with <CRLF>.<CRLF>")
+
+	dotReader := s.text.DotReader()
+	reader := dotReader
+	if s.receiver.MaxMessageBytes > 0 {
+		reader = io.LimitReader(reader, s.receiver.MaxMessageBytes+1)
+	}
+	s.conn.SetDeadline(time.Now().Add(receiverCommandTimeout))
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		log.Print("MailReceiver: ", err)
+		s.reset()
+		return
+	}
+
+	code, text := 250, "OK"
+	if s.receiver.MaxMessageBytes > 0 && int64(len(data)) > s.receiver.MaxMessageBytes {
+		// Drain the rest of the message before replying
+		io.Copy(ioutil.Discard, dotReader)
+		code, text = 552, "Message size exceeds fixed maximum message size"
+	} else if msg, err := ParseMessage(bytes.NewReader(data)); err != nil {
+		code, text = 554, "Message could not be parsed"
+	} else if s.receiver.Handler != nil {
+		s.receiver.Handler(*msg, s.recipients)
+	}
+
+	if s.receiver.LMTP {
+		// LMTP replies once per accepted recipient
+		for range s.recipients {
+			s.reply(code, text)
+		}
+	} else {
+		s.reply(code, text)
+	}
+	s.reset()
+}
+
+// IsRelayRecipient reports whether address is in Domain or one of RelayAddresses.
+// Mail to the relay is forwarded mail whose headers name the registered address.
+func (r *MailReceiver) IsRelayRecipient(address string) bool {
+	if len(r.Domain) > 0 && strings.EqualFold(AddressDomain(address), r.Domain) {
+		return true
+	}
+	for _, relayAddress := range r.RelayAddresses {
+		if strings.EqualFold(address, relayAddress) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptRecipient accepts the relay and recipients approved by ValidRecipient
+func (r *MailReceiver) acceptRecipient(address string) bool {
+	if r.IsRelayRecipient(address) {
+		return true
+	}
+	return r.ValidRecipient != nil && r.ValidRecipient(address)
+}
+
+// AddRecipients adds recipients missing from the headers of msg to its
+// Envelope.Bcc, so that they are matched like the other addresses
+func AddRecipients(msg *imap.Message, recipients []string) {
+	if msg.Envelope == nil {
+		return
+	}
+	for _, recipient := range recipients {
+		if !containsAddress(msg.Envelope, recipient) {
+			msg.Envelope.Bcc = append(msg.Envelope.Bcc, ConvertAddress(&mail.Address{Address: recipient}))
+		}
+	}
+}
+
+// parsePath parses "FROM:<path> PARAM=VALUE ..." style arguments
+func parsePath(arg, prefix string) (string, map[string]string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", nil, false
+	}
+	arg = strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(arg, "<") {
+		return "", nil, false
+	}
+	end := strings.IndexByte(arg, '>')
+	if end < 0 {
+		return "", nil, false
+	}
+	path := arg[1:end]
+
+	params := make(map[string]string)
+	for _, param := range strings.Fields(arg[end+1:]) {
+		key, value := param, ""
+		if i := strings.IndexByte(param, '='); i >= 0 {
+			key, value = param[:i], param[i+1:]
+		}
+		params[strings.ToUpper(key)] = value
+	}
+	return path, params, true
+}
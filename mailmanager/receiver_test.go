@@ -0,0 +1,165 @@
+package mailmanager
+
+import (
+	"net"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-imap"
+)
+
+type receivedMessage struct {
+	msg        imap.Message
+	recipients []string
+}
+
+// dialReceiver serves r on one end of a pipe and returns the client end
+func dialReceiver(t *testing.T, r *MailReceiver) (*textproto.Conn, chan receivedMessage) {
+	received := make(chan receivedMessage, 1)
+	r.Handler = func(msg imap.Message, recipients []string) {
+		received <- receivedMessage{msg, recipients}
+	}
+	server, client := net.Pipe()
+	go r.serve(server)
+
+	c := textproto.NewConn(client)
+	if _, _, err := c.ReadResponse(220); err != nil {
+		t.Fatal(err)
+	}
+	return c, received
+}
+
+func command(t *testing.T, c *textproto.Conn, expectCode int, format string, args ...interface{}) {
+	t.Helper()
+	if err := c.PrintfLine(format, args...); err != nil {
+		t.Fatal(err)
+	}
+	if code, message, err := c.ReadResponse(expectCode); err != nil {
+		t.Fatalf("%s: got %d %s, want %d", format, code, message, expectCode)
+	}
+}
+
+func newTestReceiver(lmtp bool) *MailReceiver {
+	return &MailReceiver{
+		Domain:         "relay.example.com",
+		RelayAddresses: []string{"bot@example.net"},
+		LMTP:           lmtp,
+		ValidRecipient: func(address string) bool {
+			return address == "taro@example.org"
+		},
+	}
+}
+
+const testReceivedMessage = "From: Alice <alice@example.com>\r\n" +
+	"To: taro@example.jp\r\n" +
+	"Subject: forwarded\r\n" +
+	"\r\n" +
+	"hello\r\n"
+
+func TestReceiverRcpt(t *testing.T) {
+	tests := []struct {
+		address string
+		code    int
+	}{
+		{address: "anything@relay.example.com", code: 250},
+		{address: "Anything@RELAY.example.com", code: 250},
+		{address: "bot@example.net", code: 250},
+		{address: "taro@example.org", code: 250},
+		{address: "jiro@example.org", code: 550},
+		{address: "bot@relay.example.com.evil", code: 550},
+	}
+	c, _ := dialReceiver(t, newTestReceiver(false))
+	defer c.Close()
+	command(t, c, 250, "EHLO client.example.com")
+	command(t, c, 250, "MAIL FROM:<alice@example.com>")
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			command(t, c, tt.code, "RCPT TO:<%s>", tt.address)
+		})
+	}
+}
+
+func TestReceiverRejectsOutOfOrderCommands(t *testing.T) {
+	c, _ := dialReceiver(t, newTestReceiver(false))
+	defer c.Close()
+	command(t, c, 503, "MAIL FROM:<alice@example.com>")
+	command(t, c, 250, "EHLO client.example.com")
+	command(t, c, 503, "RCPT TO:<bot@example.net>")
+	command(t, c, 250, "MAIL FROM:<alice@example.com>")
+	command(t, c, 503, "DATA")
+	command(t, c, 500, "LHLO client.example.com")
+}
+
+func TestReceiverSMTPData(t *testing.T) {
+	c, received := dialReceiver(t, newTestReceiver(false))
+	defer c.Close()
+	command(t, c, 250, "EHLO client.example.com")
+	command(t, c, 250, "MAIL FROM:<alice@example.com>")
+	command(t, c, 250, "RCPT TO:<bot@example.net>")
+	command(t, c, 250, "RCPT TO:<taro@example.org>")
+	command(t, c, 354, "DATA")
+	command(t, c, 250, "%s.", testReceivedMessage)
+
+	got := <-received
+	if want := []string{"bot@example.net", "taro@example.org"}; !reflect.DeepEqual(got.recipients, want) {
+		t.Errorf("recipients = %v, want %v", got.recipients, want)
+	}
+	if got.msg.Envelope.Subject != "forwarded" {
+		t.Errorf("Subject = %q", got.msg.Envelope.Subject)
+	}
+	// The headers are passed as they are; the handler decides what to add
+	if len(got.msg.Envelope.Bcc) != 0 {
+		t.Errorf("Bcc = %v, want none", got.msg.Envelope.Bcc)
+	}
+	command(t, c, 221, "QUIT")
+}
+
+func TestReceiverLMTPData(t *testing.T) {
+	c, received := dialReceiver(t, newTestReceiver(true))
+	defer c.Close()
+	command(t, c, 500, "EHLO client.example.com")
+	command(t, c, 250, "LHLO client.example.com")
+	command(t, c, 250, "MAIL FROM:<alice@example.com>")
+	command(t, c, 250, "RCPT TO:<one@relay.example.com>")
+	command(t, c, 550, "RCPT TO:<nobody@example.org>")
+	command(t, c, 250, "RCPT TO:<two@relay.example.com>")
+	command(t, c, 354, "DATA")
+	if err := c.PrintfLine("%s.", testReceivedMessage); err != nil {
+		t.Fatal(err)
+	}
+	// One reply per accepted recipient
+	for i := 0; i < 2; i++ {
+		if _, _, err := c.ReadResponse(250); err != nil {
+			t.Fatalf("reply %d: %v", i+1, err)
+		}
+	}
+	if got := <-received; len(got.recipients) != 2 {
+		t.Errorf("recipients = %v, want 2", got.recipients)
+	}
+}
+
+func TestReceiverMaxMessageBytes(t *testing.T) {
+	r := newTestReceiver(false)
+	r.MaxMessageBytes = 64
+	c, _ := dialReceiver(t, r)
+	defer c.Close()
+	command(t, c, 250, "EHLO client.example.com")
+	command(t, c, 552, "MAIL FROM:<alice@example.com> SIZE=1000")
+	command(t, c, 250, "MAIL FROM:<alice@example.com>")
+	command(t, c, 250, "RCPT TO:<bot@example.net>")
+	command(t, c, 354, "DATA")
+	command(t, c, 552, "%s%s.", testReceivedMessage, strings.Repeat("x\r\n", 64))
+}
+
+func TestAddRecipients(t *testing.T) {
+	msg, err := ParseMessage(strings.NewReader(testReceivedMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AddRecipients(msg, []string{"TARO@example.jp", "hanako@example.jp"})
+	if len(msg.Envelope.Bcc) != 1 || msg.Envelope.Bcc[0].MailboxName+"@"+msg.Envelope.Bcc[0].HostName != "hanako@example.jp" {
+		t.Errorf("Bcc = %v, want only hanako@example.jp", msg.Envelope.Bcc)
+	}
+}
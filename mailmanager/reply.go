@@ -0,0 +1,66 @@
+package mailmanager
+
+import (
+	"bytes"
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+// ReplyTarget holds what is needed to reply to a message
+type ReplyTarget struct {
+	To         MailAddress
+	Subject    string
+	InReplyTo  string
+	References string
+	// Recipients are the original To, Cc and Bcc addresses
+	Recipients []MailAddress
+}
+
+// NewReplyTarget reads reply information from a raw message
+func NewReplyTarget(raw []byte) (ReplyTarget, error) {
+	msg, err := ParseMessage(bytes.NewReader(raw))
+	if err != nil {
+		return ReplyTarget{}, err
+	}
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return ReplyTarget{}, err
+	}
+
+	var to []MailAddress
+	if to = NewMailAddressList(msg.Envelope.ReplyTo); len(to) == 0 {
+		to = EnvelopeOriginator(msg.Envelope)
+	}
+	if len(to) == 0 {
+		return ReplyTarget{}, errors.New("no address to reply to")
+	}
+
+	// Decoded encoded-words may hold line breaks meant to inject headers
+	subject := sanitizeHeaderText(msg.Envelope.Subject)
+	if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+		subject = "Re: " + subject
+	}
+
+	messageID := strings.TrimSpace(sanitizeHeaderText(msg.Envelope.MessageId))
+	references := strings.TrimSpace(sanitizeHeaderText(m.Header.Get("References")))
+	if len(references) == 0 {
+		references = strings.TrimSpace(sanitizeHeaderText(msg.Envelope.InReplyTo))
+	}
+	if len(messageID) > 0 {
+		references = strings.TrimSpace(references + " " + messageID)
+	}
+
+	var recipients []MailAddress
+	recipients = append(recipients, NewMailAddressList(msg.Envelope.To)...)
+	recipients = append(recipients, NewMailAddressList(msg.Envelope.Cc)...)
+	recipients = append(recipients, NewMailAddressList(msg.Envelope.Bcc)...)
+
+	return ReplyTarget{
+		To:         to[0],
+		Subject:    subject,
+		InReplyTo:  messageID,
+		References: references,
+		Recipients: recipients,
+	}, nil
+}
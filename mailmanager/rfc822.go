@@ -0,0 +1,119 @@
+package mailmanager
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/mail"
+	"strings"
+
+	"github.com/emersion/go-imap"
+)
+
+var headerDecoder = &mime.WordDecoder{
+	CharsetReader: CharsetReader,
+}
+
+// DecodeHeader decodes RFC 2047 encoded-words, returning the raw value on failure
+func DecodeHeader(value string) string {
+	decoded, err := headerDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// rawSection is the BODY[] section holding the whole message
+var rawSection = &imap.BodySectionName{}
+
+// RawFetchItem is the fetch item for the whole message without setting \Seen
+func RawFetchItem() imap.FetchItem {
+	section := &imap.BodySectionName{Peek: true}
+	return section.FetchItem()
+}
+
+// MessageRaw returns the whole message, or nil if it was not fetched
+func MessageRaw(msg imap.Message) []byte {
+	for section, literal := range msg.Body {
+		if !rawSection.Equal(section) || literal == nil {
+			continue
+		}
+		raw, err := ioutil.ReadAll(literal)
+		if err != nil {
+			return nil
+		}
+		// Keep the literal readable for later calls
+		msg.Body[section] = bytes.NewBuffer(raw)
+		return raw
+	}
+	return nil
+}
+
+// ParseMessage reads a RFC 5322 message and builds an imap.Message with its Envelope
+func ParseMessage(r io.Reader) (*imap.Message, error) {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	envelope := &imap.Envelope{
+		Subject:   DecodeHeader(m.Header.Get("Subject")),
+		From:      parseAddressHeader(m.Header, "From"),
+		Sender:    parseAddressHeader(m.Header, "Sender"),
+		ReplyTo:   parseAddressHeader(m.Header, "Reply-To"),
+		To:        parseAddressHeader(m.Header, "To"),
+		Cc:        parseAddressHeader(m.Header, "Cc"),
+		Bcc:       parseAddressHeader(m.Header, "Bcc"),
+		InReplyTo: m.Header.Get("In-Reply-To"),
+		MessageId: m.Header.Get("Message-Id"),
+	}
+	if date, err := m.Header.Date(); err == nil {
+		envelope.Date = date
+	}
+
+	msg := imap.NewMessage(0, []imap.FetchItem{imap.FetchEnvelope, RawFetchItem()})
+	msg.Envelope = envelope
+	msg.Body[rawSection] = bytes.NewBuffer(raw)
+	return msg, nil
+}
+
+// ConvertAddress converts a net/mail address into an imap.Address
+func ConvertAddress(address *mail.Address) *imap.Address {
+	mailboxName := address.Address
+	hostName := ""
+	if i := strings.LastIndex(address.Address, "@"); i >= 0 {
+		mailboxName = address.Address[:i]
+		hostName = address.Address[i+1:]
+	}
+	return &imap.Address{
+		PersonalName: address.Name,
+		MailboxName:  mailboxName,
+		HostName:     hostName,
+	}
+}
+
+// ParseAddressList parses an address list header value into imap.Address
+func ParseAddressList(value string) []*imap.Address {
+	if len(strings.TrimSpace(value)) == 0 {
+		return nil
+	}
+	parser := mail.AddressParser{WordDecoder: headerDecoder}
+	list, err := parser.ParseList(value)
+	if err != nil {
+		return nil
+	}
+	addresses := make([]*imap.Address, 0, len(list))
+	for _, address := range list {
+		addresses = append(addresses, ConvertAddress(address))
+	}
+	return addresses
+}
+
+func parseAddressHeader(header mail.Header, key string) []*imap.Address {
+	return ParseAddressList(header.Get(key))
+}
@@ -2,78 +2,245 @@ package mailmanager
 
 import (
 	"crypto/tls"
-	"fmt"
-	"log"
+	"errors"
 	"net"
-	"net/mail"
 	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+)
+
+// SMTP connection security
+const (
+	// SMTPSecurityTLS connects with implicit TLS (SMTPS, port 465)
+	SMTPSecurityTLS = "tls"
+	// SMTPSecuritySTARTTLS requires STARTTLS before authentication
+	SMTPSecuritySTARTTLS = "starttls"
+	// SMTPSecuritySTARTTLSOptional uses STARTTLS only when the server offers it
+	SMTPSecuritySTARTTLSOptional = "starttls-optional"
+	// SMTPSecurityNone never uses TLS. Only for local test servers.
+	SMTPSecurityNone = "none"
 )
 
-// SendMail with SMTPS
-func SendMail(from, to mail.Address, subject, body, smptServerName, smtpAuthUser, smtpAuthPassword string) {
+// SMTP authentication mechanisms
+const (
+	SMTPAuthPlain   = "plain"
+	SMTPAuthLogin   = "login"
+	SMTPAuthCRAMMD5 = "cram-md5"
+	SMTPAuthXOAuth2 = "xoauth2"
+	SMTPAuthNone    = "none"
+)
+
+const defaultSMTPTimeout = 30 * time.Second
+
+// SMTPOptions configures how mail is submitted
+type SMTPOptions struct {
+	// ServerName is "host:port"
+	ServerName    string
+	Security      string
+	AuthMechanism string
+	AuthUser      string
+	// AuthPassword is the password, or the OAuth2 access token for XOAUTH2
+	AuthPassword string
+	Timeout      time.Duration
+}
+
+// CurrentSMTPOptions returns the options set by environment variables
+func CurrentSMTPOptions() SMTPOptions {
+	configVars := helper.ConfigVars()
+	opts := SMTPOptions{
+		ServerName:    configVars.SMTP.ServerName,
+		Security:      strings.ToLower(configVars.SMTP.Security),
+		AuthMechanism: strings.ToLower(configVars.SMTP.AuthMechanism),
+		AuthUser:      configVars.SMTP.AuthUser,
+		AuthPassword:  configVars.SMTP.AuthPassword,
+		Timeout:       time.Duration(helper.Int64OrDefault(configVars.SMTP.TimeoutSeconds, 0)) * time.Second,
+	}
+	return opts
+}
 
-	// Parse header
-	headers := make(map[string]string)
-	headers["From"] = from.String()
-	headers["To"] = to.String()
-	headers["Subject"] = subject
+func (opts SMTPOptions) security(port string) string {
+	if len(opts.Security) > 0 {
+		return opts.Security
+	}
+	if port == "465" {
+		return SMTPSecurityTLS
+	}
+	return SMTPSecuritySTARTTLS
+}
 
-	// Concat header and body
-	var message string
-	for key, value := range headers {
-		message += fmt.Sprintf("%s: %s\r\n", key, value)
+func (opts SMTPOptions) timeout() time.Duration {
+	if opts.Timeout > 0 {
+		return opts.Timeout
 	}
-	message += "\r\n"
-	message += body
+	return defaultSMTPTimeout
+}
 
-	// SMTP and SMTP-Auth Setting
-	host, _, _ := net.SplitHostPort(smptServerName)
-	auth := smtp.PlainAuth("", smtpAuthUser, smtpAuthPassword, host)
+// sendSMTP submits a rendered message with the given envelope.
+// Messages are sent through SMTPMailer, which renders and signs them.
+func sendSMTP(message []byte, envelopeFrom string, recipients []string, opts SMTPOptions) error {
+	host, port, err := net.SplitHostPort(opts.ServerName)
+	if err != nil {
+		return err
+	}
+	security := opts.security(port)
 
 	// TLS setting
 	tlsconfig := &tls.Config{ServerName: host}
 
 	// Dial up SMTP Server
-	c, err := smtp.Dial(smptServerName)
+	dialer := &net.Dialer{Timeout: opts.timeout()}
+	var conn net.Conn
+	switch security {
+	case SMTPSecurityTLS:
+		conn, err = tls.DialWithDialer(dialer, "tcp", opts.ServerName, tlsconfig)
+	case SMTPSecuritySTARTTLS, SMTPSecuritySTARTTLSOptional, SMTPSecurityNone:
+		conn, err = dialer.Dial("tcp", opts.ServerName)
+	default:
+		return errors.New("smtp: unknown security " + security)
+	}
+	if err != nil {
+		return err
+	}
+	// The whole transaction must finish within the timeout
+	conn.SetDeadline(time.Now().Add(opts.timeout()))
+
+	c, err := smtp.NewClient(conn, host)
 	if err != nil {
-		log.Panic(err)
+		conn.Close()
+		return err
 	}
+	defer c.Close()
 
 	// Starting TLS
-	c.StartTLS(tlsconfig)
+	if security == SMTPSecuritySTARTTLS || security == SMTPSecuritySTARTTLSOptional {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err = c.StartTLS(tlsconfig); err != nil {
+				return err
+			}
+		} else if security == SMTPSecuritySTARTTLS {
+			return errors.New("smtp: server does not support STARTTLS")
+		}
+	}
 
-	// SMTP-Auth over TLS
-	if err = c.Auth(auth); err != nil {
-		log.Panic(err)
+	// SMTP-Auth
+	if auth, err := opts.auth(host); err != nil {
+		return err
+	} else if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server does not support AUTH")
+		}
+		if err = c.Auth(auth); err != nil {
+			return err
+		}
 	}
 
 	// SMTP COMMAND: MAIL FROM
-	if err = c.Mail(from.Address); err != nil {
-		log.Panic(err)
+	if err = c.Mail(envelopeFrom); err != nil {
+		return err
 	}
 
 	// SMTP COMMAND: RCPT TO
-	if err = c.Rcpt(to.Address); err != nil {
-		log.Panic(err)
+	for _, recipient := range recipients {
+		if err = c.Rcpt(recipient); err != nil {
+			return err
+		}
 	}
 
 	// SMTP COMMAND: DATA
 	w, err := c.Data()
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
 	// Send DATA
-	_, err = w.Write([]byte(message))
-	if err != nil {
-		log.Panic(err)
+	if _, err = w.Write(message); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
 	}
 
-	err = w.Close()
-	if err != nil {
-		log.Panic(err)
+	return c.Quit()
+}
+
+// auth returns the smtp.Auth for the configured mechanism, or nil for none
+func (opts SMTPOptions) auth(host string) (smtp.Auth, error) {
+	mechanism := opts.AuthMechanism
+	if len(mechanism) == 0 {
+		mechanism = SMTPAuthPlain
+	}
+	if mechanism == SMTPAuthNone || len(opts.AuthUser) == 0 {
+		return nil, nil
+	}
+
+	switch mechanism {
+	case SMTPAuthPlain:
+		return smtp.PlainAuth("", opts.AuthUser, opts.AuthPassword, host), nil
+	case SMTPAuthLogin:
+		return &loginAuth{username: opts.AuthUser, password: opts.AuthPassword, host: host}, nil
+	case SMTPAuthCRAMMD5:
+		return smtp.CRAMMD5Auth(opts.AuthUser, opts.AuthPassword), nil
+	case SMTPAuthXOAuth2:
+		return &xoauth2Auth{username: opts.AuthUser, token: opts.AuthPassword, host: host}, nil
 	}
+	return nil, errors.New("smtp: unknown auth mechanism " + mechanism)
+}
+
+// isLocalhost reports whether host is a loopback name
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+// loginAuth implements the LOGIN mechanism
+type loginAuth struct {
+	username, password, host string
+}
 
-	c.Quit()
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// Like smtp.PlainAuth, never send credentials in clear to remote hosts
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("smtp: unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("smtp: wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
 
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	}
+	return nil, errors.New("smtp: unexpected LOGIN challenge " + string(fromServer))
+}
+
+// xoauth2Auth implements the XOAUTH2 mechanism
+type xoauth2Auth struct {
+	username, token, host string
+}
+
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("smtp: unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("smtp: wrong host name")
+	}
+	return "XOAUTH2", []byte("user=" + a.username + "\x01auth=Bearer " + a.token + "\x01\x01"), nil
+}
+
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		// The server sent an error detail; an empty response ends the exchange
+		return []byte{}, nil
+	}
+	return nil, nil
 }
@@ -0,0 +1,147 @@
+package mongodb
+
+import (
+	"log"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// ConversationStateTTL removes states left behind. Each state also has its own timeout.
+const ConversationStateTTL = 24 * time.Hour
+
+// ConversationState is the step of a multi-step flow in a chat
+type ConversationState struct {
+	LineID    string              `bson:"line_id"`
+	State     string              `bson:"state"`
+	Values    map[string][]string `bson:"values"`
+	ExpiresAt time.Time           `bson:"expires_at"`
+	UpdatedAt time.Time           `bson:"updated_at"`
+}
+
+// CreateIndexForConversationState ..
+func CreateIndexForConversationState(url string) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("ConversationState")
+
+	//Create Index
+	indexes := []mgo.Index{
+		{
+			Key:    []string{"line_id"},
+			Unique: true,
+		}, {
+			Key:         []string{"updated_at"},
+			ExpireAfter: ConversationStateTTL,
+		},
+	}
+	for _, index := range indexes {
+		err = col.EnsureIndex(index)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// CreateOrUpdateConversationState ..
+func CreateOrUpdateConversationState(conversationState ConversationState, url string) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("ConversationState")
+
+	if _, err := col.Upsert(bson.M{"line_id": conversationState.LineID}, &conversationState); err != nil {
+		log.Println(err)
+	}
+}
+
+// ReadConversationState ..
+func ReadConversationState(lineID string, url string) ConversationState {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("ConversationState")
+
+	// Find ConversationState by ConversationState.LineID
+	conversationState := ConversationState{}
+	query := col.Find(bson.M{"line_id": lineID})
+	query.One(&conversationState)
+
+	return conversationState
+}
+
+// DeleteConversationState ..
+func DeleteConversationState(lineID string, url string) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("ConversationState")
+
+	// Remove ConversationState by ConversationState.LineID
+	if _, err := col.RemoveAll(bson.M{"line_id": lineID}); err != nil {
+		log.Println(err)
+	}
+}
+
+// MigrateOnConfigureUsers passes the users who were entering addresses in the
+// former "OnConfigureUser" collection to migrate, then drops the collection
+func MigrateOnConfigureUsers(migrate func(lineID string, addresses []string), url string) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	names, err := db.CollectionNames()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	found := false
+	for _, name := range names {
+		if name == "OnConfigureUser" {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return
+	}
+	col := db.C("OnConfigureUser")
+
+	var onConfigureUsers []struct {
+		LineID    string   `bson:"line_id"`
+		Addresses []string `bson:"address"`
+	}
+	if err := col.Find(nil).All(&onConfigureUsers); err != nil {
+		log.Println(err)
+		return
+	}
+	for _, onConfigureUser := range onConfigureUsers {
+		migrate(onConfigureUser.LineID, onConfigureUser.Addresses)
+	}
+	if err := col.DropCollection(); err != nil {
+		log.Println(err)
+		return
+	}
+	log.Printf("Migrated %d LINE users configuring addresses", len(onConfigureUsers))
+}
@@ -0,0 +1,120 @@
+package mongodb
+
+import (
+	"log"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// DeferredNotificationTTL drops held notifications that were never pushed
+const DeferredNotificationTTL = 7 * 24 * time.Hour
+
+// DeferredNotification is a notification held during quiet hours
+type DeferredNotification struct {
+	ID     bson.ObjectId `bson:"_id,omitempty"`
+	LineID string        `bson:"line_id"`
+	// MailObjects is the JSON of the held mails. Their type belongs to mailmanager.
+	MailObjects []byte    `bson:"mail_objects"`
+	CreatedAt   time.Time `bson:"created_at"`
+}
+
+// CreateIndexForDeferredNotification ..
+func CreateIndexForDeferredNotification(url string) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("DeferredNotification")
+
+	//Create Index
+	indexes := []mgo.Index{
+		{
+			Key: []string{"line_id"},
+		}, {
+			Key:         []string{"created_at"},
+			ExpireAfter: DeferredNotificationTTL,
+		},
+	}
+	for _, index := range indexes {
+		err = col.EnsureIndex(index)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// CreateDeferredNotification ..
+func CreateDeferredNotification(deferredNotification DeferredNotification, url string) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("DeferredNotification")
+
+	if err := col.Insert(&deferredNotification); err != nil {
+		log.Println(err)
+	}
+}
+
+// ReadDeferredNotifications returns every held notification, oldest first
+func ReadDeferredNotifications(url string) []DeferredNotification {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("DeferredNotification")
+
+	deferredNotifications := []DeferredNotification{}
+	if err := col.Find(nil).Sort("created_at").All(&deferredNotifications); err != nil {
+		log.Println(err)
+	}
+	return deferredNotifications
+}
+
+// DeleteDeferredNotification removes a held notification. It reports false
+// when it was already removed, e.g. pushed by another process.
+func DeleteDeferredNotification(id bson.ObjectId, url string) bool {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("DeferredNotification")
+
+	if err := col.RemoveId(id); err != nil {
+		if err != mgo.ErrNotFound {
+			log.Println(err)
+		}
+		return false
+	}
+	return true
+}
+
+// DeleteDeferredNotificationsOf removes every notification held for lineID
+func DeleteDeferredNotificationsOf(lineID string, url string) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("DeferredNotification")
+
+	if _, err := col.RemoveAll(bson.M{"line_id": lineID}); err != nil {
+		log.Println(err)
+	}
+}
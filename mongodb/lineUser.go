@@ -2,6 +2,8 @@ package mongodb
 
 import (
 	"log"
+	"sync/atomic"
+	"time"
 
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
@@ -9,9 +11,102 @@ import (
 
 // LineUser ..
 type LineUser struct {
-	LineID              string   `bson:"line_id"`
-	LineName            string   `bson:"line_name"`
-	RegisteredAddresses []string `bson:"registered_address"`
+	LineID              string              `bson:"line_id"`
+	LineName            string              `bson:"line_name"`
+	RegisteredAddresses []RegisteredAddress `bson:"addresses"`
+	Language            string              `bson:"language,omitempty"`
+	Muted               bool                `bson:"muted,omitempty"`
+	// QuietHours holds notifications until they end
+	QuietHours *QuietHours `bson:"quiet_hours,omitempty"`
+	// Filters skip notifications of matching mails
+	Filters []NotificationFilter `bson:"filters,omitempty"`
+}
+
+// QuietHours is a daily period from Start to End ("15:04" in TIME_ZONE).
+// It spans midnight when End is before Start.
+type QuietHours struct {
+	Start string `bson:"start"`
+	End   string `bson:"end"`
+}
+
+// Contains reports whether the clock time of t is in the quiet hours
+func (q *QuietHours) Contains(t time.Time) bool {
+	if q == nil {
+		return false
+	}
+	start, err := time.Parse("15:04", q.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse("15:04", q.End)
+	if err != nil {
+		return false
+	}
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+	minute := t.Hour()*60 + t.Minute()
+	if startMinute <= endMinute {
+		return startMinute <= minute && minute < endMinute
+	}
+	return minute >= startMinute || minute < endMinute
+}
+
+// Fields of mails a NotificationFilter looks at
+const (
+	FilterFieldFrom    = "from"
+	FilterFieldTo      = "to"
+	FilterFieldSubject = "subject"
+)
+
+// NotificationFilter skips mails whose Field contains Pattern, ignoring case
+type NotificationFilter struct {
+	Field   string `bson:"field"`
+	Pattern string `bson:"pattern"`
+}
+
+// RegisteredAddress is an address a LineUser receives notifications for
+type RegisteredAddress struct {
+	Address string `bson:"address"`
+	// Label, Emoji and Color tell addresses apart in notifications
+	Label string `bson:"label,omitempty"`
+	Emoji string `bson:"emoji,omitempty"`
+	Color string `bson:"color,omitempty"`
+	// Muted pauses notifications until resumed
+	Muted bool `bson:"muted,omitempty"`
+	// MutedUntil pauses notifications until the time
+	MutedUntil time.Time `bson:"muted_until,omitempty"`
+}
+
+// IsMuted reports whether notifications for the address are paused at t
+func (a RegisteredAddress) IsMuted(t time.Time) bool {
+	return a.Muted || t.Before(a.MutedUntil)
+}
+
+// AddressList returns the registered addresses
+func (u LineUser) AddressList() []string {
+	addresses := make([]string, 0, len(u.RegisteredAddresses))
+	for _, registeredAddress := range u.RegisteredAddresses {
+		addresses = append(addresses, registeredAddress.Address)
+	}
+	return addresses
+}
+
+// FindRegisteredAddress returns the entry of address, or nil
+func (u LineUser) FindRegisteredAddress(address string) *RegisteredAddress {
+	for i := range u.RegisteredAddresses {
+		if u.RegisteredAddresses[i].Address == address {
+			return &u.RegisteredAddresses[i]
+		}
+	}
+	return nil
+}
+
+// lineUserRevision is incremented whenever LineUser documents are written
+var lineUserRevision int64
+
+// LineUserRevision returns a counter changed by every LineUser write in this process
+func LineUserRevision() int64 {
+	return atomic.LoadInt64(&lineUserRevision)
 }
 
 // CreateIndexForLineUser ..
@@ -26,14 +121,86 @@ func CreateIndexForLineUser(url string) {
 	col := db.C("LineUser")
 
 	//Create Index
-	index := mgo.Index{
-		Key:    []string{"line_id"},
-		Unique: true,
+	indexes := []mgo.Index{
+		{
+			Key:    []string{"line_id"},
+			Unique: true,
+		}, {
+			Key: []string{"addresses.address"},
+		},
+	}
+	for _, index := range indexes {
+		err = col.EnsureIndex(index)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// MigrateRegisteredAddresses converts addresses stored as strings in
+// "registered_address" to entries in "addresses". normalize gives the stored
+// form of each address; addresses that become equal are merged.
+func MigrateRegisteredAddresses(normalize func(address string) string, url string) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("LineUser")
+
+	var legacyUsers []struct {
+		LineID    string   `bson:"line_id"`
+		Addresses []string `bson:"registered_address"`
 	}
-	err = col.EnsureIndex(index)
+	if err := col.Find(bson.M{"registered_address": bson.M{"$exists": true}}).All(&legacyUsers); err != nil {
+		log.Println(err)
+		return
+	}
+	for _, legacyUser := range legacyUsers {
+		update := bson.M{
+			"$set":   bson.M{"addresses": legacyRegisteredAddresses(legacyUser.Addresses, normalize)},
+			"$unset": bson.M{"registered_address": ""},
+		}
+		if err := col.Update(bson.M{"line_id": legacyUser.LineID}, update); err != nil {
+			log.Println(err)
+		}
+	}
+	if len(legacyUsers) > 0 {
+		log.Printf("Migrated registered addresses of %d LINE users", len(legacyUsers))
+		atomic.AddInt64(&lineUserRevision, 1)
+	}
+
+	// The index of the old field is no longer used
+	indexes, err := col.Indexes()
 	if err != nil {
-		log.Fatal(err)
+		log.Println(err)
+		return
+	}
+	for _, index := range indexes {
+		if len(index.Key) == 1 && index.Key[0] == "registered_address" {
+			if err := col.DropIndexName(index.Name); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// legacyRegisteredAddresses converts a legacy address list into entries,
+// normalizing addresses and dropping empty and duplicate ones
+func legacyRegisteredAddresses(addresses []string, normalize func(address string) string) []RegisteredAddress {
+	entries := make([]RegisteredAddress, 0, len(addresses))
+	seen := make(map[string]bool)
+	for _, address := range addresses {
+		address = normalize(address)
+		if len(address) == 0 || seen[address] {
+			continue
+		}
+		seen[address] = true
+		entries = append(entries, RegisteredAddress{Address: address})
 	}
+	return entries
 }
 
 // CreateOrUpdateLineUser ..
@@ -50,6 +217,172 @@ func CreateOrUpdateLineUser(lineUser LineUser, url string) {
 	if _, err := col.Upsert(bson.M{"line_id": lineUser.LineID}, &lineUser); err != nil {
 		log.Println(err)
 	}
+	atomic.AddInt64(&lineUserRevision, 1)
+}
+
+// UpdateLineUserLanguage sets the language of a LineUser, creating it if needed
+func UpdateLineUserLanguage(lineID string, language string, url string) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("LineUser")
+
+	if _, err := col.Upsert(bson.M{"line_id": lineID}, bson.M{"$set": bson.M{"language": language}}); err != nil {
+		log.Println(err)
+	}
+	atomic.AddInt64(&lineUserRevision, 1)
+}
+
+// UpdateLineUserMuted pauses or resumes notifications of a LineUser
+func UpdateLineUserMuted(lineID string, muted bool, url string) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("LineUser")
+
+	if err := col.Update(bson.M{"line_id": lineID}, bson.M{"$set": bson.M{"muted": muted}}); err != nil && err != mgo.ErrNotFound {
+		log.Println(err)
+	}
+	atomic.AddInt64(&lineUserRevision, 1)
+}
+
+// UpdateLineUserQuietHours sets the quiet hours of a LineUser. nil removes them.
+func UpdateLineUserQuietHours(lineID string, quietHours *QuietHours, url string) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("LineUser")
+
+	update := bson.M{"$set": bson.M{"quiet_hours": quietHours}}
+	if quietHours == nil {
+		update = bson.M{"$unset": bson.M{"quiet_hours": ""}}
+	}
+	if err := col.Update(bson.M{"line_id": lineID}, update); err != nil && err != mgo.ErrNotFound {
+		log.Println(err)
+	}
+	atomic.AddInt64(&lineUserRevision, 1)
+}
+
+// UpdateLineUserFilters replaces the notification filters of a LineUser
+func UpdateLineUserFilters(lineID string, filters []NotificationFilter, url string) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("LineUser")
+
+	update := bson.M{"$set": bson.M{"filters": filters}}
+	if len(filters) == 0 {
+		update = bson.M{"$unset": bson.M{"filters": ""}}
+	}
+	if err := col.Update(bson.M{"line_id": lineID}, update); err != nil && err != mgo.ErrNotFound {
+		log.Println(err)
+	}
+	atomic.AddInt64(&lineUserRevision, 1)
+}
+
+// DeleteRegisteredAddress removes one registered address of a LineUser.
+// It reports whether the address was registered.
+func DeleteRegisteredAddress(lineID string, address string, url string) bool {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("LineUser")
+
+	selector := bson.M{"line_id": lineID, "addresses.address": address}
+	if err := col.Update(selector, bson.M{"$pull": bson.M{"addresses": bson.M{"address": address}}}); err != nil {
+		if err != mgo.ErrNotFound {
+			log.Println(err)
+		}
+		return false
+	}
+	atomic.AddInt64(&lineUserRevision, 1)
+	return true
+}
+
+// UpdateRegisteredAddressMute pauses notifications for one registered
+// address until resumed when muted, or until mutedUntil. Both zero resume
+// notifications. It reports whether the address was registered.
+func UpdateRegisteredAddressMute(lineID string, address string, muted bool, mutedUntil time.Time, url string) bool {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("LineUser")
+
+	update := bson.M{"$set": bson.M{"addresses.$.muted": muted, "addresses.$.muted_until": mutedUntil}}
+	if !muted && mutedUntil.IsZero() {
+		update = bson.M{"$unset": bson.M{"addresses.$.muted": "", "addresses.$.muted_until": ""}}
+	}
+	selector := bson.M{"line_id": lineID, "addresses.address": address}
+	if err := col.Update(selector, update); err != nil {
+		if err != mgo.ErrNotFound {
+			log.Println(err)
+		}
+		return false
+	}
+	atomic.AddInt64(&lineUserRevision, 1)
+	return true
+}
+
+// UpdateRegisteredAddressLabel sets how one registered address is shown in
+// notifications. Empty values are removed. It reports whether the address was registered.
+func UpdateRegisteredAddressLabel(lineID string, address string, label string, emoji string, color string, url string) bool {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("LineUser")
+
+	set, unset := bson.M{}, bson.M{}
+	for field, value := range map[string]string{"label": label, "emoji": emoji, "color": color} {
+		if len(value) > 0 {
+			set["addresses.$."+field] = value
+		} else {
+			unset["addresses.$."+field] = ""
+		}
+	}
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	selector := bson.M{"line_id": lineID, "addresses.address": address}
+	if err := col.Update(selector, update); err != nil {
+		if err != mgo.ErrNotFound {
+			log.Println(err)
+		}
+		return false
+	}
+	atomic.AddInt64(&lineUserRevision, 1)
+	return true
 }
 
 // ReadAllLineUsers ..
@@ -90,6 +423,28 @@ func ReadLineUser(lineID string, url string) LineUser {
 	return lineUser
 }
 
+// ExistsRegisteredAddress reports whether any of addresses is registered.
+// Muted addresses are included so that mail to them is not bounced.
+func ExistsRegisteredAddress(addresses []string, url string) bool {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("LineUser")
+
+	// Count LineUsers who registered the address
+	count, err := col.Find(bson.M{"addresses.address": bson.M{"$in": addresses}}).Count()
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	return count > 0
+}
+
 // DeleteAllLineUsers ..
 func DeleteAllLineUsers(url string) {
 	session, err := mgo.Dial(url)
@@ -105,6 +460,7 @@ func DeleteAllLineUsers(url string) {
 	if _, err := col.RemoveAll(bson.M{}); err != nil {
 		log.Println(err)
 	}
+	atomic.AddInt64(&lineUserRevision, 1)
 }
 
 // DeleteLineUser ..
@@ -122,4 +478,5 @@ func DeleteLineUser(lineID string, url string) {
 	if _, err := col.RemoveAll(bson.M{"line_id": lineID}); err != nil {
 		log.Println(err)
 	}
+	atomic.AddInt64(&lineUserRevision, 1)
 }
@@ -0,0 +1,85 @@
+package mongodb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuietHoursContains(t *testing.T) {
+	at := func(clock string) time.Time {
+		t, _ := time.Parse("15:04", clock)
+		return t
+	}
+	tests := []struct {
+		name       string
+		quietHours *QuietHours
+		clock      string
+		want       bool
+	}{
+		{name: "none", quietHours: nil, clock: "23:00", want: false},
+		{name: "inside", quietHours: &QuietHours{Start: "12:00", End: "13:00"}, clock: "12:30", want: true},
+		{name: "at start", quietHours: &QuietHours{Start: "12:00", End: "13:00"}, clock: "12:00", want: true},
+		{name: "at end", quietHours: &QuietHours{Start: "12:00", End: "13:00"}, clock: "13:00", want: false},
+		{name: "over midnight before", quietHours: &QuietHours{Start: "22:00", End: "07:00"}, clock: "23:59", want: true},
+		{name: "over midnight after", quietHours: &QuietHours{Start: "22:00", End: "07:00"}, clock: "06:59", want: true},
+		{name: "over midnight outside", quietHours: &QuietHours{Start: "22:00", End: "07:00"}, clock: "12:00", want: false},
+		{name: "invalid", quietHours: &QuietHours{Start: "late", End: "07:00"}, clock: "23:00", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.quietHours.Contains(at(tt.clock)); got != tt.want {
+				t.Errorf("Contains(%s) = %v, want %v", tt.clock, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisteredAddressIsMuted(t *testing.T) {
+	now := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		address RegisteredAddress
+		want    bool
+	}{
+		{name: "not muted", address: RegisteredAddress{Address: "a@example.com"}, want: false},
+		{name: "muted", address: RegisteredAddress{Address: "a@example.com", Muted: true}, want: true},
+		{name: "snoozed until the future", address: RegisteredAddress{Address: "a@example.com", MutedUntil: now.Add(time.Minute)}, want: true},
+		{name: "snooze ended", address: RegisteredAddress{Address: "a@example.com", MutedUntil: now.Add(-time.Minute)}, want: false},
+		{name: "snooze ends now", address: RegisteredAddress{Address: "a@example.com", MutedUntil: now}, want: false},
+		{name: "muted after the snooze ended", address: RegisteredAddress{Address: "a@example.com", Muted: true, MutedUntil: now.Add(-time.Minute)}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.address.IsMuted(now); got != tt.want {
+				t.Errorf("IsMuted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLegacyRegisteredAddresses(t *testing.T) {
+	tests := []struct {
+		name      string
+		addresses []string
+		want      []RegisteredAddress
+	}{
+		{name: "none", addresses: nil, want: []RegisteredAddress{}},
+		{
+			name:      "normalized and deduplicated",
+			addresses: []string{"Taro@Example.com", "taro@example.com", " ", "hanako@example.com"},
+			want:      []RegisteredAddress{{Address: "taro@example.com"}, {Address: "hanako@example.com"}},
+		},
+	}
+	normalize := func(address string) string {
+		return strings.ToLower(strings.TrimSpace(address))
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := legacyRegisteredAddresses(tt.addresses, normalize); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package mongodb
+
+import (
+	"log"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// MailArchiveTTL is how long notified mails are kept for on-demand actions
+const MailArchiveTTL = 7 * 24 * time.Hour
+
+// MailArchive ..
+type MailArchive struct {
+	MailID    string    `bson:"mail_id"`
+	LineIDs   []string  `bson:"line_ids"`
+	Raw       []byte    `bson:"raw"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+// CreateIndexForMailArchive ..
+func CreateIndexForMailArchive(url string) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("MailArchive")
+
+	//Create Index
+	indexes := []mgo.Index{
+		{
+			Key:    []string{"mail_id"},
+			Unique: true,
+		}, {
+			Key:         []string{"created_at"},
+			ExpireAfter: MailArchiveTTL,
+		},
+	}
+	for _, index := range indexes {
+		err = col.EnsureIndex(index)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// CreateOrUpdateMailArchive stores the mail and adds LineIDs allowed to read it
+func CreateOrUpdateMailArchive(mailArchive MailArchive, url string) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("MailArchive")
+
+	update := bson.M{
+		"$set":      bson.M{"raw": mailArchive.Raw, "created_at": mailArchive.CreatedAt},
+		"$addToSet": bson.M{"line_ids": bson.M{"$each": mailArchive.LineIDs}},
+	}
+	if _, err := col.Upsert(bson.M{"mail_id": mailArchive.MailID}, update); err != nil {
+		log.Println(err)
+	}
+}
+
+// ReadMailArchive ..
+func ReadMailArchive(mailID string, url string) MailArchive {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("MailArchive")
+
+	// Find MailArchive by MailArchive.MailID
+	mailArchive := MailArchive{}
+	query := col.Find(bson.M{"mail_id": mailID})
+	query.One(&mailArchive)
+
+	return mailArchive
+}
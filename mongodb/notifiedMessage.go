@@ -0,0 +1,110 @@
+package mongodb
+
+import (
+	"log"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// NotifiedMessageTTL is how long notified messages are remembered
+const NotifiedMessageTTL = 30 * 24 * time.Hour
+
+// NotifiedMessage ..
+type NotifiedMessage struct {
+	LineID     string    `bson:"line_id"`
+	MessageKey string    `bson:"message_key"`
+	CreatedAt  time.Time `bson:"created_at"`
+}
+
+// CreateIndexForNotifiedMessage ..
+func CreateIndexForNotifiedMessage(url string) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("NotifiedMessage")
+
+	//Create Index
+	indexes := []mgo.Index{
+		{
+			Key:    []string{"line_id", "message_key"},
+			Unique: true,
+		}, {
+			Key:         []string{"created_at"},
+			ExpireAfter: NotifiedMessageTTL,
+		},
+	}
+	for _, index := range indexes {
+		err = col.EnsureIndex(index)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// ClaimNotifiedMessage records that notifiedMessage is being notified.
+// It returns false when another process already claimed the message, using
+// the unique index on line_id and message_key.
+func ClaimNotifiedMessage(notifiedMessage NotifiedMessage, url string) bool {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("NotifiedMessage")
+
+	if err := col.Insert(&notifiedMessage); err != nil {
+		if !mgo.IsDup(err) {
+			log.Println(err)
+		}
+		return false
+	}
+	return true
+}
+
+// ReleaseNotifiedMessage deletes a claim of ClaimNotifiedMessage so that the
+// message can be notified again
+func ReleaseNotifiedMessage(lineID string, messageKey string, url string) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("NotifiedMessage")
+
+	if err := col.Remove(bson.M{"line_id": lineID, "message_key": messageKey}); err != nil && err != mgo.ErrNotFound {
+		log.Println(err)
+	}
+}
+
+// ReadNotifiedMessageKeys returns which of messageKeys are already notified to lineID
+func ReadNotifiedMessageKeys(lineID string, messageKeys []string, url string) map[string]bool {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("NotifiedMessage")
+
+	// Find NotifiedMessage by NotifiedMessage.LineID and NotifiedMessage.MessageKey
+	notifiedMessages := []NotifiedMessage{}
+	query := col.Find(bson.M{"line_id": lineID, "message_key": bson.M{"$in": messageKeys}})
+	query.All(&notifiedMessages)
+
+	notified := make(map[string]bool, len(notifiedMessages))
+	for _, notifiedMessage := range notifiedMessages {
+		notified[notifiedMessage.MessageKey] = true
+	}
+	return notified
+}
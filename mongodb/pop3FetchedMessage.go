@@ -0,0 +1,94 @@
+package mongodb
+
+import (
+	"log"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// Pop3FetchedMessage ..
+type Pop3FetchedMessage struct {
+	Account   string    `bson:"account"`
+	UIDL      string    `bson:"uidl"`
+	FetchedAt time.Time `bson:"fetched_at"`
+}
+
+// CreateIndexForPop3FetchedMessage ..
+func CreateIndexForPop3FetchedMessage(url string) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("Pop3FetchedMessage")
+
+	//Create Index
+	index := mgo.Index{
+		Key:    []string{"account", "uidl"},
+		Unique: true,
+	}
+	err = col.EnsureIndex(index)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// CreateOrUpdatePop3FetchedMessage ..
+func CreateOrUpdatePop3FetchedMessage(pop3FetchedMessage Pop3FetchedMessage, url string) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("Pop3FetchedMessage")
+
+	if _, err := col.Upsert(bson.M{"account": pop3FetchedMessage.Account, "uidl": pop3FetchedMessage.UIDL}, &pop3FetchedMessage); err != nil {
+		log.Println(err)
+	}
+}
+
+// ReadPop3FetchedUIDLs ..
+func ReadPop3FetchedUIDLs(account string, url string) map[string]bool {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("Pop3FetchedMessage")
+
+	// Find Pop3FetchedMessage by Pop3FetchedMessage.Account
+	pop3FetchedMessages := []Pop3FetchedMessage{}
+	query := col.Find(bson.M{"account": account})
+	query.All(&pop3FetchedMessages)
+
+	uidls := make(map[string]bool, len(pop3FetchedMessages))
+	for _, pop3FetchedMessage := range pop3FetchedMessages {
+		uidls[pop3FetchedMessage.UIDL] = true
+	}
+	return uidls
+}
+
+// DeletePop3FetchedMessagesExcept removes UIDLs that are no longer on the server
+func DeletePop3FetchedMessagesExcept(account string, uidls []string, url string) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		log.Fatal("mgo.Dial: ", err)
+	}
+	defer session.Close()
+
+	db := session.DB("")
+	col := db.C("Pop3FetchedMessage")
+
+	// Remove Pop3FetchedMessage by Pop3FetchedMessage.Account and not in uidls
+	if _, err := col.RemoveAll(bson.M{"account": account, "uidl": bson.M{"$nin": uidls}}); err != nil {
+		log.Println(err)
+	}
+}
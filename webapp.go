@@ -23,9 +23,18 @@ func main() {
 
 	// Init DB
 	mongodbURL := configVars.MongodbURI
+	lineapi.MigrateRegisteredAddresses()
+	lineapi.MigrateOnConfigureUsers()
 	mongodb.CreateIndexForLineUser(mongodbURL)
-	mongodb.CreateIndexForOnConfigureUser(mongodbURL)
 	mongodb.CreateIndexForVerificationPendingAddress(mongodbURL)
+	mongodb.CreateIndexForPop3FetchedMessage(mongodbURL)
+	mongodb.CreateIndexForNotifiedMessage(mongodbURL)
+	mongodb.CreateIndexForMailArchive(mongodbURL)
+	mongodb.CreateIndexForConversationState(mongodbURL)
+	mongodb.CreateIndexForDeferredNotification(mongodbURL)
+
+	// Create the rich menu of LINE_RICH_MENU_FILE
+	lineapi.ProvisionRichMenu()
 
 	// Start Keep-Alive Worker for Heroku
 	herokuAppName := configVars.HerokuAppName
@@ -36,14 +45,40 @@ func main() {
 		log.Println("Start Keep-Alive Worker for Heroku")
 	}
 
-	// Start MailCheckWorker
-	interval := 5 * time.Minute
-	go workers.MailCheckWorker(interval)
-	log.Println("Start MailCheck Worker")
+	// Start MailCheckWorker when IMAP or POP3 polling is configured
+	if len(configVars.IMAP.ServerName) > 0 || len(configVars.POP3.ServerName) > 0 {
+		interval := 5 * time.Minute
+		go workers.MailCheckWorker(interval)
+		log.Println("Start MailCheck Worker")
+	}
+
+	// Start NotificationFlushWorker for notifications held during quiet hours
+	go workers.NotificationFlushWorker(time.Minute)
+
+	// Start MailReceiveWorker when inbound SMTP/LMTP is enabled
+	receiverListenAddress := configVars.Receiver.ListenAddress
+	if len(receiverListenAddress) > 0 {
+		go workers.MailReceiveWorker(receiverListenAddress)
+		log.Println("Start MailReceive Worker on " + receiverListenAddress)
+	}
 
 	// Start http server for linebot webhook
 	port := configVars.Port
 	http.HandleFunc("/", lineapi.WebhookHandler)
+	http.HandleFunc(lineapi.ContentPath, lineapi.ContentHandler)
+	if len(configVars.LineAPI.LIFFID) > 0 && len(configVars.LineAPI.LoginChannelID) > 0 {
+		http.HandleFunc(lineapi.LIFFPath, lineapi.LIFFHandler)
+		http.HandleFunc(lineapi.LIFFAPIPath, lineapi.LIFFAPIHandler)
+		log.Println("Serve LIFF settings page on " + lineapi.LIFFPath)
+	}
+	if len(configVars.InboundParse.SigningKey) > 0 {
+		inboundParsePath := configVars.InboundParse.Path
+		if len(inboundParsePath) == 0 {
+			inboundParsePath = "/inbound"
+		}
+		http.HandleFunc(inboundParsePath, lineapi.InboundParseHandler)
+		log.Println("Accept inbound-parse webhook on " + inboundParsePath)
+	}
 	if err := http.ListenAndServe(":"+port, nil); err != nil {
 		log.Fatal("ListenAndServe: ", err)
 	}
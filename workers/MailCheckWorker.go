@@ -4,10 +4,10 @@ import (
 	"log"
 	"time"
 
+	"github.com/emersion/go-imap"
 	"github.com/mshrtsr/mail-notice-linebot/helper"
 	"github.com/mshrtsr/mail-notice-linebot/lineapi"
 	"github.com/mshrtsr/mail-notice-linebot/mailmanager"
-	"github.com/mshrtsr/mail-notice-linebot/mongodb"
 )
 
 // MailCheck ..
@@ -17,21 +17,25 @@ func MailCheck() {
 	dateSince := time.Now().AddDate(0, 0, -2)
 	dateBefore := time.Now().AddDate(0, 0, 2)
 
-	//messages := mailmanager.FetchMail(dateSince, dateBefore, mboxName, configVars.IMAP.ServerName, configVars.IMAP.AuthUser, configVars.IMAP.AuthPassword)
-	messages := mailmanager.PopMail(dateSince, dateBefore, mboxName, configVars.IMAP.ServerName, configVars.IMAP.AuthUser, configVars.IMAP.AuthPassword)
+	var messages []imap.Message
+	if len(configVars.POP3.ServerName) > 0 {
+		deleteMail := configVars.POP3.KeepMail != "true"
+		var err error
+		messages, err = mailmanager.PopMailPOP3(deleteMail, configVars.POP3.ServerName, configVars.POP3.AuthUser, configVars.POP3.AuthPassword, configVars.MongodbURI)
+		if err != nil {
+			// Mails fetched before the error are still notified; the rest
+			// are tried again on the next tick
+			log.Print("PopMailPOP3: ", err)
+		}
+	} else {
+		//messages := mailmanager.FetchMail(dateSince, dateBefore, mboxName, configVars.IMAP.ServerName, configVars.IMAP.AuthUser, configVars.IMAP.AuthPassword)
+		messages = mailmanager.PopMail(dateSince, dateBefore, mboxName, configVars.IMAP.ServerName, configVars.IMAP.AuthUser, configVars.IMAP.AuthPassword)
+	}
 	log.Println("fetched messages: ", len(messages))
 	// for _, msg := range messages {
 	// 	log.Println(msg.Envelope.Date.String() + ":" + msg.Envelope.Subject)
 	// }
-	if len(messages) > 0 {
-		lineUsers := mongodb.ReadAllLineUsers(configVars.MongodbURI)
-
-		userMailObjects := mailmanager.ConvertMessagesToUserMailObject(messages, lineUsers)
-
-		if len(userMailObjects) > 0 {
-			lineapi.SendPushNotification(userMailObjects)
-		}
-	}
+	lineapi.NotifyMessages(messages)
 }
 
 // MailCheckWorker ..
@@ -0,0 +1,59 @@
+package workers
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-imap"
+	"github.com/mshrtsr/mail-notice-linebot/helper"
+	"github.com/mshrtsr/mail-notice-linebot/lineapi"
+	"github.com/mshrtsr/mail-notice-linebot/mailmanager"
+	"github.com/mshrtsr/mail-notice-linebot/mongodb"
+)
+
+// MailReceiveWorker runs the inbound SMTP/LMTP receiver and notifies received mail
+func MailReceiveWorker(listenAddress string) {
+	configVars := helper.ConfigVars()
+
+	var maxMessageBytes int64
+	if len(configVars.Receiver.MaxMessageBytes) > 0 {
+		n, err := strconv.ParseInt(configVars.Receiver.MaxMessageBytes, 10, 64)
+		if err != nil {
+			log.Fatal("RECEIVER_MAX_MESSAGE_BYTES: ", err)
+		}
+		maxMessageBytes = n
+	}
+
+	var relayAddresses []string
+	if len(configVars.IMAP.Address) > 0 {
+		relayAddresses = append(relayAddresses, configVars.IMAP.Address)
+	}
+	receiver := &mailmanager.MailReceiver{
+		Addr:            listenAddress,
+		Domain:          configVars.Receiver.Domain,
+		RelayAddresses:  relayAddresses,
+		LMTP:            strings.EqualFold(configVars.Receiver.Protocol, "lmtp"),
+		MaxMessageBytes: maxMessageBytes,
+		ValidRecipient: func(address string) bool {
+			candidates := mailmanager.RegisteredAddressCandidates(address, mailmanager.CurrentAddressMatchOptions())
+			return mongodb.ExistsRegisteredAddress(candidates, configVars.MongodbURI)
+		},
+	}
+	receiver.Handler = func(msg imap.Message, recipients []string) {
+		log.Println("received message: ", msg.Envelope.Subject, recipients)
+		// Mail forwarded to the relay is matched by its headers like IMAP mail.
+		// Registered addresses delivered directly may be missing from them.
+		var direct []string
+		for _, recipient := range recipients {
+			if !receiver.IsRelayRecipient(recipient) {
+				direct = append(direct, recipient)
+			}
+		}
+		mailmanager.AddRecipients(&msg, direct)
+		lineapi.NotifyMessages([]imap.Message{msg})
+	}
+	if err := receiver.ListenAndServe(); err != nil {
+		log.Fatal("MailReceiver: ", err)
+	}
+}
@@ -0,0 +1,18 @@
+package workers
+
+import (
+	"time"
+
+	"github.com/mshrtsr/mail-notice-linebot/lineapi"
+)
+
+// NotificationFlushWorker pushes notifications held during quiet hours
+func NotificationFlushWorker(interval time.Duration) {
+	tic := time.NewTicker(interval)
+	for {
+		select {
+		case <-tic.C:
+			lineapi.FlushDeferredNotifications()
+		}
+	}
+}